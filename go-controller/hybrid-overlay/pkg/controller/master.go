@@ -3,6 +3,7 @@ package controller
 import (
 	"fmt"
 	"net"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,16 +14,18 @@ import (
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/informer"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/kube"
+	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/metrics"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/ovn/subnetallocator"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/util"
 
 	kapi "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/labels"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/sets"
 	listers "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/klog"
 	utilnet "k8s.io/utils/net"
 )
@@ -36,6 +39,21 @@ type MasterController struct {
 	podEventHandler       informer.EventHandler
 	ovnNBClient           goovn.Client
 	ovnSBClient           goovn.Client
+	recorder              record.EventRecorder
+
+	// DryRun makes the controller only log the changes it would make to
+	// hybrid overlay ports, node annotations and subnet allocations, rather
+	// than making them
+	DryRun bool
+
+	// healthLock protects the state reported by HealthCheck
+	healthLock sync.Mutex
+	// handledNodes holds the nodes that have been handled at least once,
+	// until the initial sync is done
+	handledNodes sets.String
+	// initialSyncDone is set once every node that existed at startup
+	// has been handled at least once
+	initialSyncDone bool
 }
 
 // NewMaster a new master controller that listens for node events
@@ -45,13 +63,17 @@ func NewMaster(kube kube.Interface,
 	podInformer cache.SharedIndexInformer,
 	ovnNBClient goovn.Client,
 	ovnSBClient goovn.Client,
+	recorder record.EventRecorder,
 ) (*MasterController, error) {
 
 	m := &MasterController{
-		kube:        kube,
-		allocator:   subnetallocator.NewSubnetAllocator(),
-		ovnNBClient: ovnNBClient,
-		ovnSBClient: ovnSBClient,
+		kube:         kube,
+		allocator:    subnetallocator.NewSubnetAllocator(),
+		ovnNBClient:  ovnNBClient,
+		ovnSBClient:  ovnSBClient,
+		recorder:     recorder,
+		DryRun:       config.HybridOverlay.DryRun,
+		handledNodes: sets.NewString(),
 	}
 
 	m.nodeEventHandler = informer.NewDefaultEventHandler("node", nodeInformer,
@@ -60,13 +82,16 @@ func NewMaster(kube kube.Interface,
 			if !ok {
 				return fmt.Errorf("object is not a node")
 			}
-			return m.AddNode(node)
+			err := m.AddNode(node)
+			m.recordNodeResult(node, err)
+			return err
 		},
 		func(obj interface{}) error {
 			node, ok := obj.(*kapi.Node)
 			if !ok {
 				return fmt.Errorf("object is not a node")
 			}
+			m.forgetNodeResult(node.Name)
 			return m.DeleteNode(node)
 		},
 		informer.ReceiveAllUpdates,
@@ -81,8 +106,11 @@ func NewMaster(kube kube.Interface,
 			return m.AddNamespace(ns)
 		},
 		func(obj interface{}) error {
-			// discard deletes
-			return nil
+			ns, ok := obj.(*kapi.Namespace)
+			if !ok {
+				return fmt.Errorf("object is not a namespace")
+			}
+			return m.DeleteNamespace(ns)
 		},
 		nsHybridAnnotationChanged,
 	)
@@ -121,12 +149,16 @@ func NewMaster(kube kube.Interface,
 			klog.Warningf(err.Error())
 		} else if hostsubnet != nil {
 			klog.V(5).Infof("Marking existing node %s hybrid overlay NodeSubnet %s as allocated", node.Name, hostsubnet)
-			if err := m.allocator.MarkAllocatedNetwork(hostsubnet); err != nil {
+			if err := m.allocator.AllocateNetworkForKey(node.Name, hostsubnet); err != nil {
 				utilruntime.HandleError(err)
 			}
 		}
 	}
 
+	metrics.RegisterHybridOverlayMasterMetrics()
+	metrics.RegisterHealthCheck("hybrid-overlay-master", m.HealthCheck)
+	m.updateSubnetUsageMetrics()
+
 	return m, nil
 }
 
@@ -160,12 +192,88 @@ func (m *MasterController) Run(stopCh <-chan struct{}) {
 			klog.Error(err)
 		}
 	}()
+	if config.HybridOverlay.PortReconcileInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(time.Duration(config.HybridOverlay.PortReconcileInterval) * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					m.reconcileOverlayPorts()
+				case <-stopCh:
+					return
+				}
+			}
+		}()
+	}
 	<-stopCh
 	klog.Info("Shutting down Hybrid Overlay Master workers")
 	wg.Wait()
 	klog.Info("Shut down Hybrid Overlay Master workers")
 }
 
+// recordNodeResult records that a node has been handled, for HealthCheck,
+// and reports a failure to handle it as a node event and in the metrics
+func (m *MasterController) recordNodeResult(node *kapi.Node, err error) {
+	if err != nil {
+		m.recordNodeError(node, err)
+	}
+	m.healthLock.Lock()
+	defer m.healthLock.Unlock()
+	if !m.initialSyncDone {
+		m.handledNodes.Insert(node.Name)
+	}
+}
+
+// recordNodeError reports a failure to set up a node for the hybrid overlay
+func (m *MasterController) recordNodeError(node *kapi.Node, err error) {
+	m.recorder.Eventf(node, kapi.EventTypeWarning, "FailedHybridOverlaySetup",
+		"Error setting up hybrid overlay for node: %v", err)
+	metrics.RecordHybridOverlayNodeError()
+}
+
+// forgetNodeResult drops the recorded result of a deleted node
+func (m *MasterController) forgetNodeResult(nodeName string) {
+	m.healthLock.Lock()
+	defer m.healthLock.Unlock()
+	m.handledNodes.Delete(nodeName)
+}
+
+// HealthCheck returns an error until every node that existed when the
+// controller started has been handled at least once, whether or not that
+// succeeded. Failures to handle individual nodes are reported by events and
+// metrics instead, so that one broken node doesn't make the controller
+// unhealthy.
+func (m *MasterController) HealthCheck() error {
+	m.healthLock.Lock()
+	defer m.healthLock.Unlock()
+
+	if m.initialSyncDone {
+		return nil
+	}
+	if !m.nodeEventHandler.Synced() {
+		return fmt.Errorf("hybrid overlay node cache has not synced yet")
+	}
+	for _, nodeName := range m.nodeEventHandler.GetIndexer().ListKeys() {
+		if !m.handledNodes.Has(nodeName) {
+			return fmt.Errorf("hybrid overlay node %s has not been handled yet", nodeName)
+		}
+	}
+	m.initialSyncDone = true
+	m.handledNodes = sets.NewString()
+	return nil
+}
+
+// updateSubnetUsageMetrics records the current allocation counts of each
+// hybrid overlay cluster subnet
+func (m *MasterController) updateSubnetUsageMetrics() {
+	for _, usage := range m.allocator.Usage() {
+		metrics.RecordHybridOverlaySubnetUsage(usage.CIDR, usage.Allocated, usage.Total)
+	}
+}
+
 // hybridOverlayNodeEnsureSubnet allocates a subnet and sets the
 // hybrid overlay subnet annotation. It returns any newly allocated subnet
 // or an error. If an error occurs, the newly allocated subnet will be released.
@@ -174,10 +282,21 @@ func (m *MasterController) hybridOverlayNodeEnsureSubnet(node *kapi.Node, annota
 	if subnet, _ := houtil.ParseHybridOverlayHostSubnet(node); subnet != nil {
 		return nil, nil
 	}
+	if m.DryRun {
+		klog.Infof("Dry run: would allocate a hybrid overlay HostSubnet for node %s", node.Name)
+		return nil, nil
+	}
 
-	// Allocate a new host subnet for this node
-	hostsubnets, err := m.allocator.AllocateNetworks()
+	// Allocate a host subnet for this node; if the node's annotation was
+	// removed while its subnet is still allocated, it gets the same one back
+	hostsubnets, err := m.allocator.AllocateOrReuse(node.Name)
 	if err != nil {
+		clusterSubnets := make([]string, 0, len(config.HybridOverlay.ClusterSubnets))
+		for _, clusterEntry := range config.HybridOverlay.ClusterSubnets {
+			clusterSubnets = append(clusterSubnets, clusterEntry.CIDR.String())
+		}
+		m.recorder.Eventf(node, kapi.EventTypeWarning, "FailedHybridOverlaySubnetAllocation",
+			"Error allocating hybrid overlay HostSubnet from %s: %v", strings.Join(clusterSubnets, ","), err)
 		return nil, fmt.Errorf("error allocating hybrid overlay HostSubnet for node %s: %v", node.Name, err)
 	}
 
@@ -185,6 +304,7 @@ func (m *MasterController) hybridOverlayNodeEnsureSubnet(node *kapi.Node, annota
 		_ = m.allocator.ReleaseNetwork(hostsubnets[0])
 		return nil, err
 	}
+	m.updateSubnetUsageMetrics()
 
 	klog.Infof("Allocated hybrid overlay HostSubnet %s for node %s", hostsubnets[0], node.Name)
 	return hostsubnets[0], nil
@@ -194,28 +314,66 @@ func (m *MasterController) releaseNodeSubnet(nodeName string, subnet *net.IPNet)
 	if err := m.allocator.ReleaseNetwork(subnet); err != nil {
 		return fmt.Errorf("error deleting hybrid overlay HostSubnet %s for node %q: %s", subnet, nodeName, err)
 	}
+	m.updateSubnetUsageMetrics()
 	klog.Infof("Deleted hybrid overlay HostSubnet %s for node %s", subnet, nodeName)
 	return nil
 }
 
-// handleOverlayPort reconciles the node's overlay port with OVN.
+// runOVNNbctl runs a mutating ovn-nbctl command, or only logs it in dry-run mode
+func (m *MasterController) runOVNNbctl(args ...string) (string, string, error) {
+	if m.DryRun {
+		klog.Infof("Dry run: would execute ovn-nbctl %s", strings.Join(args, " "))
+		return "", "", nil
+	}
+	return util.RunOVNNbctl(args...)
+}
+
+// runAnnotator applies the node annotation changes queued on annotator, or
+// only logs that they would be applied in dry-run mode
+func (m *MasterController) runAnnotator(node *kapi.Node, annotator kube.Annotator) error {
+	if m.DryRun {
+		klog.Infof("Dry run: would update hybrid overlay annotations of node %s", node.Name)
+		return nil
+	}
+	return annotator.Run()
+}
+
+// updateNodeSwitchExcludeIPs updates the node switch's excluded IPs for the
+// given subnet, or only logs it in dry-run mode
+func (m *MasterController) updateNodeSwitchExcludeIPs(nodeName string, subnet *net.IPNet) error {
+	if m.DryRun {
+		klog.Infof("Dry run: would update logical switch %s exclude_ips for subnet %s", nodeName, subnet)
+		return nil
+	}
+	return util.UpdateNodeSwitchExcludeIPs(nodeName, subnet)
+}
+
+// handleOverlayPort reconciles the node's overlay port with OVN. It returns
+// true if it created a new lsp, even when an error occurred after the lsp was
+// created, so that the caller can remove it again on failure.
 // It needs to handle the following cases:
 //   - no subnet allocated: unset MAC annotation
 //   - no MAC annotation, no lsp: configure lsp, set annotation
 //   - annotation, no lsp: configure lsp
 //   - annotation, lsp: ensure lsp matches annotation
 //   - no annotation, lsp: set annotation from lsp
-func (m *MasterController) handleOverlayPort(node *kapi.Node, annotator kube.Annotator) error {
+func (m *MasterController) handleOverlayPort(node *kapi.Node, annotator kube.Annotator) (bool, error) {
 	var err error
 	var annotationMAC, portMAC net.HardwareAddr
+	var lspIPs []net.IP
 	portName := util.GetHybridOverlayPortName(node.Name)
 
-	// retrieve mac annotation
+	// retrieve mac annotation; a valid one overrides the MAC that would
+	// otherwise be derived from the port's IP
 	am, annotationOK := node.Annotations[types.HybridOverlayDRMAC]
 	if annotationOK {
 		annotationMAC, err = net.ParseMAC(am)
+		if err == nil {
+			err = houtil.ValidateDRMAC(annotationMAC)
+		}
 		if err != nil {
-			klog.Errorf("MAC annotation %s on node %s is invalid, ignoring.", annotationMAC, node.Name)
+			klog.Errorf("MAC annotation %s on node %s is invalid, ignoring: %v", am, node.Name, err)
+			annotationMAC = nil
 			annotationOK = false
 		}
 	}
@@ -229,19 +387,23 @@ func (m *MasterController) handleOverlayPort(node *kapi.Node, annotator kube.Ann
 			m.deleteOverlayPort(node)
 			annotator.Delete(types.HybridOverlayDRMAC)
 		}
-		return nil
+		return false, nil
 	}
 
+	// compute the hybrid overlay interface address for each address family;
+	// the DRMAC stays a single MAC even on dual-stack nodes
+	portIPs := houtil.GetNodeHybridOverlayIfAddrs(subnets)
+
 	// retrieve port configuration. If port isn't set up, portMAC will be nil
-	portMAC, _, _ = util.GetPortAddresses(portName, m.ovnNBClient)
+	portMAC, lspIPs, _ = util.GetPortAddresses(portName, m.ovnNBClient)
+	lspCreated := portMAC == nil && !m.DryRun
 
 	// compare port configuration to annotation MAC, reconcile as needed
 	lspOK := false
 
 	// nothing allocated, allocate default mac
 	if portMAC == nil && annotationMAC == nil {
-		for _, subnet := range subnets {
-			ip := util.GetNodeHybridOverlayIfAddr(subnet).IP
+		for _, ip := range portIPs {
 			portMAC = util.IPAddrToHWAddr(ip)
 			annotationMAC = portMAC
 			if !utilnet.IsIPv6(ip) {
@@ -263,20 +425,28 @@ func (m *MasterController) handleOverlayPort(node *kapi.Node, annotator kube.Ann
 		}
 	}
 
+	// the port must also carry an address for every node subnet family,
+	// eg when a single-stack node becomes dual-stack, and follow subnet
+	// changes; updating the port also updates the switch's excluded IPs
+	if lspOK && util.JoinIPs(lspIPs, " ") != util.JoinIPs(portIPs, " ") {
+		klog.V(2).Infof("Node %s lsp %s has stale hybrid port addresses %v, correcting", node.Name, portName, lspIPs)
+		lspOK = false
+	}
+
 	if !lspOK {
-		klog.Infof("Creating / updating node %s hybrid overlay port with mac %s", node.Name, portMAC.String())
+		klog.Infof("Creating / updating node %s hybrid overlay port with mac %s and IPs %v", node.Name, portMAC.String(), portIPs)
 
 		var stderr string
 		// create / update lsps
-		_, stderr, err = util.RunOVNNbctl("--", "--may-exist", "lsp-add", node.Name, portName,
-			"--", "lsp-set-addresses", portName, portMAC.String())
+		_, stderr, err = m.runOVNNbctl("--", "--may-exist", "lsp-add", node.Name, portName,
+			"--", "lsp-set-addresses", portName, portMAC.String()+" "+util.JoinIPs(portIPs, " "))
 		if err != nil {
-			return fmt.Errorf("failed to add hybrid overlay port for node %s"+
+			return false, fmt.Errorf("failed to add hybrid overlay port for node %s"+
 				", stderr:%s: %v", node.Name, stderr, err)
 		}
 		for _, subnet := range subnets {
-			if err := util.UpdateNodeSwitchExcludeIPs(node.Name, subnet); err != nil {
-				return err
+			if err := m.updateNodeSwitchExcludeIPs(node.Name, subnet); err != nil {
+				return lspCreated, err
 			}
 		}
 	}
@@ -284,43 +454,123 @@ func (m *MasterController) handleOverlayPort(node *kapi.Node, annotator kube.Ann
 	if !annotationOK {
 		klog.Infof("Setting node %s hybrid overlay mac annotation to %s", node.Name, annotationMAC.String())
 		if err := annotator.Set(types.HybridOverlayDRMAC, portMAC.String()); err != nil {
-			return fmt.Errorf("failed to set node %s hybrid overlay DRMAC annotation: %v", node.Name, err)
+			return lspCreated, fmt.Errorf("failed to set node %s hybrid overlay DRMAC annotation: %v", node.Name, err)
 		}
 	}
 
-	return nil
+	return lspCreated, nil
 }
 
 func (m *MasterController) deleteOverlayPort(node *kapi.Node) {
 	klog.Infof("Removing node %s hybrid overlay port", node.Name)
 	portName := util.GetHybridOverlayPortName(node.Name)
-	_, _, _ = util.RunOVNNbctl("--", "--if-exists", "lsp-del", portName)
+	_, _, _ = m.runOVNNbctl("--", "--if-exists", "lsp-del", portName)
+}
+
+// reconcileOverlayPorts recreates the hybrid overlay port of any OVN-managed
+// node that has a DRMAC annotation but no logical switch port, eg because the
+// OVN NB database was wiped. handleOverlayPort alone won't notice this since
+// nodes aren't re-added while their annotations are unchanged.
+func (m *MasterController) reconcileOverlayPorts() {
+	nodeLister := listers.NewNodeLister(m.nodeEventHandler.GetIndexer())
+	nodes, err := nodeLister.List(labels.Everything())
+	if err != nil {
+		klog.Errorf("Failed to list nodes for hybrid overlay port reconciliation: %v", err)
+		return
+	}
+	for _, node := range nodes {
+		if houtil.IsHybridOverlayNode(node) {
+			continue
+		}
+		if _, ok := node.Annotations[types.HybridOverlayDRMAC]; !ok {
+			continue
+		}
+		portName := util.GetHybridOverlayPortName(node.Name)
+		if portMAC, _, _ := util.GetPortAddresses(portName, m.ovnNBClient); portMAC != nil {
+			continue
+		}
+
+		klog.Infof("Node %s hybrid overlay port %s is missing, recreating it", node.Name, portName)
+		annotator := kube.NewNodeAnnotator(m.kube, node)
+		if _, err := m.handleOverlayPort(node, annotator); err != nil {
+			klog.Errorf("Failed to recreate hybrid overlay port for node %s: %v", node.Name, err)
+			m.recordNodeError(node, err)
+			continue
+		}
+		if err := m.runAnnotator(node, annotator); err != nil {
+			klog.Errorf("Failed to set hybrid overlay annotations for node %s: %v", node.Name, err)
+			m.recordNodeError(node, err)
+		}
+	}
+}
+
+// deleteStaleOverlayPort removes the hybrid overlay port and DRMAC annotation
+// of a node that was managed by OVN before it became a hybrid overlay node.
+// It must only be called while the node has no hybrid overlay subnet yet,
+// since the hybrid overlay node sets its own DRMAC annotation once it has one.
+func (m *MasterController) deleteStaleOverlayPort(node *kapi.Node, annotator kube.Annotator) {
+	if _, ok := node.Annotations[types.HybridOverlayDRMAC]; !ok {
+		return
+	}
+	klog.Infof("Node %s became a hybrid overlay node", node.Name)
+	m.deleteOverlayPort(node)
+	annotator.Delete(types.HybridOverlayDRMAC)
 }
 
 // AddNode handles node additions
-func (m *MasterController) AddNode(node *kapi.Node) error {
+func (m *MasterController) AddNode(node *kapi.Node) (err error) {
 	annotator := kube.NewNodeAnnotator(m.kube, node)
 
 	var allocatedSubnet *net.IPNet
+	var lspCreated bool
+	defer func() {
+		if err == nil {
+			return
+		}
+		// Undo everything done for the node so far, so that a failure
+		// doesn't leave an orphaned subnet or logical switch port behind
+		if allocatedSubnet != nil {
+			_ = m.releaseNodeSubnet(node.Name, allocatedSubnet)
+		}
+		if lspCreated {
+			m.deleteOverlayPort(node)
+		}
+	}()
+
+	// A node's labels may change at runtime, turning it from an OVN-managed
+	// node into a hybrid overlay node or vice-versa, so clean up anything
+	// left over from the node's previous type
+	var staleSubnet *net.IPNet
 	if houtil.IsHybridOverlayNode(node) {
-		var err error
+		if subnet, _ := houtil.ParseHybridOverlayHostSubnet(node); subnet == nil {
+			m.deleteStaleOverlayPort(node, annotator)
+		}
 		allocatedSubnet, err = m.hybridOverlayNodeEnsureSubnet(node, annotator)
 		if err != nil {
 			return fmt.Errorf("failed to update node %q hybrid overlay subnet annotation: %v", node.Name, err)
 		}
 	} else {
-		if err := m.handleOverlayPort(node, annotator); err != nil {
+		if staleSubnet, _ = houtil.ParseHybridOverlayHostSubnet(node); staleSubnet != nil {
+			klog.Infof("Node %s is no longer a hybrid overlay node", node.Name)
+			annotator.Delete(types.HybridOverlayNodeSubnet)
+		}
+		lspCreated, err = m.handleOverlayPort(node, annotator)
+		if err != nil {
 			return fmt.Errorf("failed to set up hybrid overlay logical switch port for %s: %v", node.Name, err)
 		}
 	}
 
-	if err := annotator.Run(); err != nil {
-		// Release allocated subnet if any errors occurred
-		if allocatedSubnet != nil {
-			_ = m.releaseNodeSubnet(node.Name, allocatedSubnet)
-		}
+	if err = m.runAnnotator(node, annotator); err != nil {
 		return fmt.Errorf("failed to set hybrid overlay annotations for %s: %v", node.Name, err)
 	}
+
+	// Only release the subnet once the annotation is gone, so that it
+	// can't be handed out to another node while this one still uses it
+	if staleSubnet != nil && !m.DryRun {
+		if err := m.releaseNodeSubnet(node.Name, staleSubnet); err != nil {
+			klog.Warningf("%v", err)
+		}
+	}
 	return nil
 }
 
@@ -338,66 +588,92 @@ func (m *MasterController) DeleteNode(node *kapi.Node) error {
 	return nil
 }
 
-// AddNamespace copies namespace annotations to all pods in the namespace
+// AddNamespace copies namespace annotations to all pods in the namespace.
+// Pods that couldn't be annotated don't stop the others from being updated,
+// but their errors are returned so that the namespace gets requeued.
 func (m *MasterController) AddNamespace(ns *kapi.Namespace) error {
+	m.validateNamespaceAnnotations(ns)
 	podLister := listers.NewPodLister(m.podEventHandler.GetIndexer())
 	pods, err := podLister.Pods(ns.Name).List(labels.Everything())
 	if err != nil {
 		return err
 	}
+	var errs []error
 	for _, pod := range pods {
 		if err := houtil.CopyNamespaceAnnotationsToPod(m.kube, ns, pod); err != nil {
-			klog.Errorf("Unable to copy hybrid-overlay namespace annotations to pod %s", pod.Name)
+			errs = append(errs, fmt.Errorf("unable to copy hybrid-overlay namespace %s annotations to pod %s: %v",
+				ns.Name, pod.Name, err))
 		}
 	}
-	return nil
+	return utilerrors.NewAggregate(errs)
 }
 
-// waitForNamespace fetches a namespace from the cache, or waits until it's available
-func (m *MasterController) waitForNamespace(name string) (*kapi.Namespace, error) {
-	var namespaceBackoff = wait.Backoff{Duration: 1 * time.Second, Steps: 7, Factor: 1.5, Jitter: 0.1}
-	var namespace *kapi.Namespace
-	if err := wait.ExponentialBackoff(namespaceBackoff, func() (bool, error) {
-		var err error
-		namespaceLister := listers.NewNamespaceLister(m.namespaceEventHandler.GetIndexer())
-		namespace, err = namespaceLister.Get(name)
-		if err != nil {
-			if errors.IsNotFound(err) {
-				// Namespace not found; retry
-				return false, nil
-			}
-			klog.Warningf("Error getting namespace: %v", err)
-			return false, err
+// validateNamespaceAnnotations posts a warning event for each hybrid overlay
+// annotation of ns that is invalid, and so won't be copied to its pods. This
+// is done once per namespace change rather than once per pod.
+func (m *MasterController) validateNamespaceAnnotations(ns *kapi.Namespace) {
+	if nsGw, ok := ns.Annotations[hotypes.HybridOverlayExternalGw]; ok {
+		if _, err := houtil.ParseExternalGws(nsGw); err != nil {
+			klog.Warningf("Invalid namespace %s annotation %s: %v", ns.Name, hotypes.HybridOverlayExternalGw, err)
+			m.recorder.Eventf(ns, kapi.EventTypeWarning, "InvalidHybridOverlayExternalGw",
+				"Invalid %s annotation: %v", hotypes.HybridOverlayExternalGw, err)
+		}
+	}
+	if nsVTEP, ok := ns.Annotations[hotypes.HybridOverlayVTEP]; ok {
+		if err := houtil.ValidateVTEP(nsVTEP); err != nil {
+			klog.Warningf("Invalid namespace %s annotation %s: %v", ns.Name, hotypes.HybridOverlayVTEP, err)
+			m.recorder.Eventf(ns, kapi.EventTypeWarning, "InvalidHybridOverlayVTEP",
+				"Invalid %s annotation: %v", hotypes.HybridOverlayVTEP, err)
 		}
-		return true, nil
-	}); err != nil {
-		return nil, fmt.Errorf("failed to get namespace object: %v", err)
 	}
-	return namespace, nil
+}
+
+// DeleteNamespace handles namespace deletions. The pods of a deleted
+// namespace are deleted along with it, so there is nothing to undo on them;
+// any per-namespace state kept by the controller must be released here.
+func (m *MasterController) DeleteNamespace(ns *kapi.Namespace) error {
+	return nil
 }
 
 // AddPod ensures that hybrid overlay annotations are copied to a
 // pod when it's created. This allows the nodes to set up the appropriate
 // flows
 func (m *MasterController) AddPod(pod *kapi.Pod) error {
-	namespace, err := m.waitForNamespace(pod.Namespace)
+	// Don't block the pod worker waiting for the namespace to show up in
+	// the cache; returning an error requeues the pod with backoff instead.
+	// Once the namespace is added, AddNamespace copies its annotations to
+	// the pod anyway if all retries were used up by then.
+	namespaceLister := listers.NewNamespaceLister(m.namespaceEventHandler.GetIndexer())
+	namespace, err := namespaceLister.Get(pod.Namespace)
 	if err != nil {
-		return err
+		return fmt.Errorf("failed to get namespace %s for pod %s: %v", pod.Namespace, pod.Name, err)
 	}
 
-	namespaceExternalGw := namespace.Annotations[hotypes.HybridOverlayExternalGw]
-	namespaceVTEP := namespace.Annotations[hotypes.HybridOverlayVTEP]
-
-	podExternalGw := pod.Annotations[hotypes.HybridOverlayExternalGw]
-	podVTEP := pod.Annotations[hotypes.HybridOverlayVTEP]
-
-	if namespaceExternalGw != podExternalGw || namespaceVTEP != podVTEP {
+	if !podHybridAnnotationsCopied(namespace, pod) {
 		// copy namespace annotations to the pod and return
 		return houtil.CopyNamespaceAnnotationsToPod(m.kube, namespace, pod)
 	}
 	return nil
 }
 
+// podHybridAnnotationsCopied returns true if copying the annotations of ns to
+// pod would not change the pod. Invalid namespace values are never copied, so
+// they leave the pod annotation as it is, including absent.
+func podHybridAnnotationsCopied(ns *kapi.Namespace, pod *kapi.Pod) bool {
+	if nsGw, ok := ns.Annotations[hotypes.HybridOverlayExternalGw]; ok {
+		if _, err := houtil.ParseExternalGws(nsGw); err == nil &&
+			!houtil.SameExternalGws(nsGw, pod.Annotations[hotypes.HybridOverlayExternalGw]) {
+			return false
+		}
+	}
+	if nsVTEP, ok := ns.Annotations[hotypes.HybridOverlayVTEP]; ok {
+		if err := houtil.ValidateVTEP(nsVTEP); err == nil && nsVTEP != pod.Annotations[hotypes.HybridOverlayVTEP] {
+			return false
+		}
+	}
+	return true
+}
+
 // nsHybridAnnotationChanged returns true if any relevant NS attributes changed
 func nsHybridAnnotationChanged(old, new interface{}) bool {
 	oldNs := old.(*kapi.Namespace)
@@ -407,7 +683,7 @@ func nsHybridAnnotationChanged(old, new interface{}) bool {
 	nsVTEPOld := oldNs.GetAnnotations()[hotypes.HybridOverlayVTEP]
 	nsExGwNew := newNs.GetAnnotations()[hotypes.HybridOverlayExternalGw]
 	nsVTEPNew := newNs.GetAnnotations()[hotypes.HybridOverlayVTEP]
-	if nsExGwOld != nsExGwNew || nsVTEPOld != nsVTEPNew {
+	if !houtil.SameExternalGws(nsExGwOld, nsExGwNew) || nsVTEPOld != nsVTEPNew {
 		return true
 	}
 	return false
@@ -15,11 +15,16 @@ import (
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 
 	"github.com/ovn-org/ovn-kubernetes/go-controller/hybrid-overlay/pkg/types"
+	houtil "github.com/ovn-org/ovn-kubernetes/go-controller/hybrid-overlay/pkg/util"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/informer"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/kube"
+	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/ovn/subnetallocator"
 	ovntest "github.com/ovn-org/ovn-kubernetes/go-controller/pkg/testing"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/util"
 
@@ -122,6 +127,7 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 				f.Core().V1().Pods().Informer(),
 				mockOVNNBClient,
 				mockOVNSBClient,
+				record.NewFakeRecorder(10),
 			)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -163,6 +169,62 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
+	It("records an event when a Windows node can't be allocated a hybrid-overlay subnet", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				node1Name string = "node1"
+				node2Name string = "node2"
+				node3Name string = "node3"
+			)
+
+			node1 := newTestNode(node1Name, "windows", "", "", "")
+			node2 := newTestNode(node2Name, "windows", "", "", "")
+			node3 := newTestNode(node3Name, "windows", "", "", "")
+			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
+				Items: []v1.Node{node1, node2, node3},
+			})
+
+			_, err := config.InitConfig(ctx, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			recorder := record.NewFakeRecorder(10)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				ovntest.NewMockOVNClient(goovn.DBNB),
+				ovntest.NewMockOVNClient(goovn.DBSB),
+				recorder,
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			// The cluster subnet only has room for two nodes
+			err = m.AddNode(&node1)
+			Expect(err).NotTo(HaveOccurred())
+			err = m.AddNode(&node2)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).NotTo(Receive())
+
+			err = m.AddNode(&node3)
+			Expect(err).To(HaveOccurred())
+			Expect(recorder.Events).To(Receive(And(
+				HavePrefix(v1.EventTypeWarning+" FailedHybridOverlaySubnetAllocation"),
+				ContainSubstring("11.1.0.0/24"),
+			)))
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=11.1.0.0/24/25",
+			hoNodeCliArg,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
 	It("sets up and cleans up a Linux node with a OVN hostsubnet annotation", func() {
 		app.Action = func(ctx *cli.Context) error {
 			const (
@@ -194,6 +256,7 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 				f.Core().V1().Pods().Informer(),
 				mockOVNNBClient,
 				mockOVNSBClient,
+				record.NewFakeRecorder(10),
 			)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -201,9 +264,224 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 				// Setting the mac on the lsp
 				"ovn-nbctl --timeout=15 -- " +
 					"--may-exist lsp-add node1 int-node1 -- " +
-					"lsp-set-addresses int-node1 " + nodeHOMAC,
+					"lsp-set-addresses int-node1 " + nodeHOMAC + " " + nodeHOIP,
+			})
+
+			fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+				Cmd:    "ovn-nbctl --timeout=15 lsp-list " + nodeName,
+				Output: "29df5ce5-2802-4ee5-891f-4fb27ca776e9 (" + util.K8sPrefix + nodeName + ")",
+			})
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- --if-exists set logical_switch " + nodeName + " other-config:exclude_ips=" + nodeHOIP,
+			})
+
+			f.Start(stopChan)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.Run(stopChan)
+			}()
+
+			Eventually(func() (map[string]string, error) {
+				updatedNode, err := fakeClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+				if err != nil {
+					return nil, err
+				}
+				return updatedNode.Annotations, nil
+			}, 2).Should(HaveKeyWithValue(types.HybridOverlayDRMAC, nodeHOMAC))
+
+			// Test that deleting the node cleans up the OVN objects
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- --if-exists lsp-del int-node1",
+			})
+
+			err = fakeClient.CoreV1().Nodes().Delete(context.TODO(), nodeName, *metav1.NewDeleteOptions(0))
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(fexec.CalledMatchesExpected, 2).Should(BeTrue(), fexec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("only logs the changes for Linux and Windows nodes in dry-run mode", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				nodeName    string = "node1"
+				nodeSubnet  string = "10.1.2.0/24"
+				winNodeName string = "winnode"
+			)
+
+			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
+				Items: []v1.Node{
+					newTestNode(nodeName, "linux", nodeSubnet, "", ""),
+					newTestNode(winNodeName, "windows", "", "", ""),
+				},
+			})
+
+			fexec := ovntest.NewFakeExec()
+			err := util.SetExec(fexec)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = config.InitConfig(ctx, fexec, nil)
+			Expect(err).NotTo(HaveOccurred())
+			mockOVNNBClient := ovntest.NewMockOVNClient(goovn.DBNB)
+			mockOVNSBClient := ovntest.NewMockOVNClient(goovn.DBSB)
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				mockOVNNBClient,
+				mockOVNSBClient,
+				record.NewFakeRecorder(10),
+			)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m.DryRun).To(BeTrue())
+
+			f.Start(stopChan)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.Run(stopChan)
+			}()
+
+			// Neither node is annotated, no subnet is allocated and no
+			// ovn-nbctl command is run
+			Eventually(m.HealthCheck, 2).Should(Succeed())
+			for _, name := range []string{nodeName, winNodeName} {
+				updatedNode, err := fakeClient.CoreV1().Nodes().Get(context.TODO(), name, metav1.GetOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(updatedNode.Annotations).NotTo(HaveKey(types.HybridOverlayDRMAC))
+				Expect(updatedNode.Annotations).NotTo(HaveKey(types.HybridOverlayNodeSubnet))
+			}
+			Expect(m.allocator.Usage()[0].Allocated).To(BeZero())
+
+			err = fakeClient.CoreV1().Nodes().Delete(context.TODO(), nodeName, *metav1.NewDeleteOptions(0))
+			Expect(err).NotTo(HaveOccurred())
+
+			Consistently(fexec.CalledMatchesExpected, 1).Should(BeTrue(), fexec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			hoNodeCliArg,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+			"-hybrid-overlay-dry-run",
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+	It("uses a valid DRMAC annotation on a Linux node instead of deriving the port MAC", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				nodeName   string = "node1"
+				nodeSubnet string = "10.1.2.0/24"
+				nodeHOIP   string = "10.1.2.3"
+				nodeDRMAC  string = "0e:00:00:00:00:01"
+				nodeHOMAC  string = "0e:00:00:00:00:01"
+			)
+
+			node := newTestNode(nodeName, "linux", nodeSubnet, "", nodeDRMAC)
+			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
+				Items: []v1.Node{node},
+			})
+
+			fexec := ovntest.NewFakeExec()
+			err := util.SetExec(fexec)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = config.InitConfig(ctx, fexec, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				ovntest.NewMockOVNClient(goovn.DBNB),
+				ovntest.NewMockOVNClient(goovn.DBSB),
+				record.NewFakeRecorder(10),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- " +
+					"--may-exist lsp-add " + nodeName + " int-" + nodeName + " -- " +
+					"lsp-set-addresses int-" + nodeName + " " + nodeHOMAC + " " + nodeHOIP,
+			})
+			fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+				Cmd:    "ovn-nbctl --timeout=15 lsp-list " + nodeName,
+				Output: "29df5ce5-2802-4ee5-891f-4fb27ca776e9 (" + util.K8sPrefix + nodeName + ")",
+			})
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- --if-exists set logical_switch " + nodeName + " other-config:exclude_ips=" + nodeHOIP,
+			})
+
+			err = m.AddNode(&node)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+
+			updatedNode, err := fakeClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updatedNode.Annotations).To(HaveKeyWithValue(types.HybridOverlayDRMAC, nodeHOMAC))
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("derives the port MAC of a Linux node whose DRMAC annotation is not locally administered", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				nodeName   string = "node1"
+				nodeSubnet string = "10.1.2.0/24"
+				nodeHOIP   string = "10.1.2.3"
+				nodeDRMAC  string = "00:00:00:00:00:01"
+				nodeHOMAC  string = "0a:58:0a:01:02:03"
+			)
+
+			node := newTestNode(nodeName, "linux", nodeSubnet, "", nodeDRMAC)
+			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
+				Items: []v1.Node{node},
 			})
 
+			fexec := ovntest.NewFakeExec()
+			err := util.SetExec(fexec)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = config.InitConfig(ctx, fexec, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				ovntest.NewMockOVNClient(goovn.DBNB),
+				ovntest.NewMockOVNClient(goovn.DBSB),
+				record.NewFakeRecorder(10),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- " +
+					"--may-exist lsp-add " + nodeName + " int-" + nodeName + " -- " +
+					"lsp-set-addresses int-" + nodeName + " " + nodeHOMAC + " " + nodeHOIP,
+			})
 			fexec.AddFakeCmd(&ovntest.ExpectedCmd{
 				Cmd:    "ovn-nbctl --timeout=15 lsp-list " + nodeName,
 				Output: "29df5ce5-2802-4ee5-891f-4fb27ca776e9 (" + util.K8sPrefix + nodeName + ")",
@@ -212,6 +490,516 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 				"ovn-nbctl --timeout=15 -- --if-exists set logical_switch " + nodeName + " other-config:exclude_ips=" + nodeHOIP,
 			})
 
+			err = m.AddNode(&node)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+
+			updatedNode, err := fakeClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updatedNode.Annotations).To(HaveKeyWithValue(types.HybridOverlayDRMAC, nodeHOMAC))
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("handles a Linux node with no annotation but an existing port", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				nodeName   string = "node1"
+				nodeSubnet string = "10.1.2.0/24"
+				nodeHOIP   string = "10.1.2.3"
+				nodeHOMAC  string = "02:00:00:52:19:d2"
+			)
+
+			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
+				Items: []v1.Node{
+					newTestNode(nodeName, "linux", nodeSubnet, "", ""),
+				},
+			})
+
+			fexec := ovntest.NewFakeExec()
+
+			err := util.SetExec(fexec)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = config.InitConfig(ctx, fexec, nil)
+			Expect(err).NotTo(HaveOccurred())
+			mockOVNNBClient := ovntest.NewMockOVNClient(goovn.DBNB)
+			mockOVNSBClient := ovntest.NewMockOVNClient(goovn.DBSB)
+
+			populatePortAddresses(nodeName, nodeHOMAC, nodeHOIP, mockOVNNBClient)
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				mockOVNNBClient,
+				mockOVNSBClient,
+				record.NewFakeRecorder(10),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			f.Start(stopChan)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.Run(stopChan)
+			}()
+
+			Eventually(func() (map[string]string, error) {
+				updatedNode, err := fakeClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
+				if err != nil {
+					return nil, err
+				}
+				return updatedNode.Annotations, nil
+			}, 2).Should(HaveKeyWithValue(types.HybridOverlayDRMAC, nodeHOMAC))
+			return nil
+		}
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("recreates a Linux node hybrid overlay port that disappeared from OVN", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				nodeName   string = "node1"
+				nodeSubnet string = "10.1.2.0/24"
+				nodeHOIP   string = "10.1.2.3"
+				nodeHOMAC  string = "02:00:00:52:19:d2"
+			)
+
+			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
+				Items: []v1.Node{
+					newTestNode(nodeName, "linux", nodeSubnet, "", nodeHOMAC),
+				},
+			})
+
+			fexec := ovntest.NewFakeExec()
+			err := util.SetExec(fexec)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = config.InitConfig(ctx, fexec, nil)
+			Expect(err).NotTo(HaveOccurred())
+			mockOVNNBClient := ovntest.NewMockOVNClient(goovn.DBNB)
+			mockOVNSBClient := ovntest.NewMockOVNClient(goovn.DBSB)
+
+			populatePortAddresses(nodeName, nodeHOMAC, nodeHOIP, mockOVNNBClient)
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				mockOVNNBClient,
+				mockOVNSBClient,
+				record.NewFakeRecorder(10),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			f.Start(stopChan)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.Run(stopChan)
+			}()
+
+			// Port is in sync with the annotation; nothing to do
+			Consistently(fexec.CalledMatchesExpected, 2).Should(BeTrue(), fexec.ErrorDesc)
+
+			// Drop the port from OVN behind the master's back
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- " +
+					"--may-exist lsp-add node1 int-node1 -- " +
+					"lsp-set-addresses int-node1 " + nodeHOMAC + " " + nodeHOIP,
+			})
+			fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+				Cmd:    "ovn-nbctl --timeout=15 lsp-list " + nodeName,
+				Output: "29df5ce5-2802-4ee5-891f-4fb27ca776e9 (" + util.K8sPrefix + nodeName + ")",
+			})
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- --if-exists set logical_switch " + nodeName + " other-config:exclude_ips=" + nodeHOIP,
+			})
+			cmd, err := mockOVNNBClient.LSPDel("int-" + nodeName)
+			Expect(err).NotTo(HaveOccurred())
+			err = cmd.Execute()
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(fexec.CalledMatchesExpected, 3).Should(BeTrue(), fexec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+			"-hybrid-overlay-port-reconcile-interval=1",
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("cleans up a Linux node when the OVN hostsubnet annotation is removed", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				nodeName   string = "node1"
+				nodeSubnet string = "10.1.2.0/24"
+				nodeHOIP   string = "10.1.2.3"
+				nodeHOMAC  string = "02:00:00:52:19:d2"
+			)
+
+			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
+				Items: []v1.Node{
+					newTestNode(nodeName, "linux", nodeSubnet, "", nodeHOMAC),
+				},
+			})
+
+			fexec := ovntest.NewFakeExec()
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- --if-exists lsp-del int-node1",
+			})
+
+			err := util.SetExec(fexec)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = config.InitConfig(ctx, fexec, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			mockOVNNBClient := ovntest.NewMockOVNClient(goovn.DBNB)
+			mockOVNSBClient := ovntest.NewMockOVNClient(goovn.DBSB)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				mockOVNNBClient,
+				mockOVNSBClient,
+				record.NewFakeRecorder(10),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			populatePortAddresses(nodeName, nodeHOMAC, nodeHOIP, mockOVNNBClient)
+
+			f.Start(stopChan)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.Run(stopChan)
+			}()
+
+			k := &kube.Kube{KClient: fakeClient}
+			updatedNode, err := k.GetNode(nodeName)
+			Expect(err).NotTo(HaveOccurred())
+
+			nodeAnnotator := kube.NewNodeAnnotator(k, updatedNode)
+			util.DeleteNodeHostSubnetAnnotation(nodeAnnotator)
+			err = nodeAnnotator.Run()
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(func() (map[string]string, error) {
+				updatedNode, err = k.GetNode(nodeName)
+				if err != nil {
+					return nil, err
+				}
+				return updatedNode.Annotations, nil
+			}, 5).ShouldNot(HaveKey(types.HybridOverlayDRMAC))
+
+			Eventually(fexec.CalledMatchesExpected, 2).Should(BeTrue(), fexec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("updates the port of a Linux node that becomes dual-stack and cleans it up", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				nodeName     string = "node1"
+				nodeSubnet   string = "10.1.2.0/24"
+				nodeSubnetV6 string = "fd00:10:1:2::/64"
+				nodeHOIP     string = "10.1.2.3"
+				nodeHOIPv6   string = "fd00:10:1:2::3"
+				nodeHOMAC    string = "0a:58:0a:01:02:03"
+			)
+
+			node := newTestNode(nodeName, "linux", "", "", nodeHOMAC)
+			subnetAnnotations, err := util.CreateNodeHostSubnetAnnotation(ovntest.MustParseIPNets(nodeSubnet, nodeSubnetV6))
+			Expect(err).NotTo(HaveOccurred())
+			for k, v := range subnetAnnotations {
+				node.Annotations[k] = fmt.Sprintf("%s", v)
+			}
+			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
+				Items: []v1.Node{node},
+			})
+
+			fexec := ovntest.NewFakeExec()
+			err = util.SetExec(fexec)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = config.InitConfig(ctx, fexec, nil)
+			Expect(err).NotTo(HaveOccurred())
+			mockOVNNBClient := ovntest.NewMockOVNClient(goovn.DBNB)
+			mockOVNSBClient := ovntest.NewMockOVNClient(goovn.DBSB)
+
+			// port was previously configured for IPv4 only
+			populatePortAddresses(nodeName, nodeHOMAC, nodeHOIP, mockOVNNBClient)
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				mockOVNNBClient,
+				mockOVNSBClient,
+				record.NewFakeRecorder(10),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- " +
+					"--may-exist lsp-add node1 int-node1 -- " +
+					"lsp-set-addresses int-node1 " + nodeHOMAC + " " + nodeHOIP + " " + nodeHOIPv6,
+			})
+			fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+				Cmd:    "ovn-nbctl --timeout=15 lsp-list " + nodeName,
+				Output: "29df5ce5-2802-4ee5-891f-4fb27ca776e9 (" + util.K8sPrefix + nodeName + ")",
+			})
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- --if-exists set logical_switch " + nodeName + " other-config:exclude_ips=" + nodeHOIP,
+			})
+
+			f.Start(stopChan)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.Run(stopChan)
+			}()
+
+			Eventually(fexec.CalledMatchesExpected, 2).Should(BeTrue(), fexec.ErrorDesc)
+
+			// Test that deleting the node cleans up the dual-stack port
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- --if-exists lsp-del int-node1",
+			})
+
+			err = fakeClient.CoreV1().Nodes().Delete(context.TODO(), nodeName, *metav1.NewDeleteOptions(0))
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(fexec.CalledMatchesExpected, 2).Should(BeTrue(), fexec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("updates the port and excluded IPs of a Linux node whose subnet changes", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				nodeName      string = "node1"
+				nodeSubnet    string = "10.1.2.0/24"
+				nodeHOIP      string = "10.1.2.3"
+				newNodeSubnet string = "10.1.3.0/24"
+				newNodeHOIP   string = "10.1.3.3"
+				nodeHOMAC     string = "0a:58:0a:01:02:03"
+			)
+
+			node := newTestNode(nodeName, "linux", nodeSubnet, "", nodeHOMAC)
+			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
+				Items: []v1.Node{node},
+			})
+
+			fexec := ovntest.NewFakeExec()
+			err := util.SetExec(fexec)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = config.InitConfig(ctx, fexec, nil)
+			Expect(err).NotTo(HaveOccurred())
+			mockOVNNBClient := ovntest.NewMockOVNClient(goovn.DBNB)
+			mockOVNSBClient := ovntest.NewMockOVNClient(goovn.DBSB)
+
+			// port is already configured for the old subnet
+			populatePortAddresses(nodeName, nodeHOMAC, nodeHOIP, mockOVNNBClient)
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				mockOVNNBClient,
+				mockOVNSBClient,
+				record.NewFakeRecorder(10),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			f.Start(stopChan)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.Run(stopChan)
+			}()
+
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- " +
+					"--may-exist lsp-add node1 int-node1 -- " +
+					"lsp-set-addresses int-node1 " + nodeHOMAC + " " + newNodeHOIP,
+			})
+			fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+				Cmd:    "ovn-nbctl --timeout=15 lsp-list " + nodeName,
+				Output: "29df5ce5-2802-4ee5-891f-4fb27ca776e9 (" + util.K8sPrefix + nodeName + ")",
+			})
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- --if-exists set logical_switch " + nodeName + " other-config:exclude_ips=" + newNodeHOIP,
+			})
+
+			updatedNode := newTestNode(nodeName, "linux", newNodeSubnet, "", nodeHOMAC)
+			_, err = fakeClient.CoreV1().Nodes().Update(context.TODO(), &updatedNode, metav1.UpdateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Eventually(fexec.CalledMatchesExpected, 2).Should(BeTrue(), fexec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("releases the subnet and removes the port when annotating a node fails", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				winNodeName   string = "node1"
+				linuxNodeName string = "node2"
+				nodeSubnet    string = "10.1.2.0/24"
+				nodeHOIP      string = "10.1.2.3"
+				nodeHOMAC     string = "0a:58:0a:01:02:03"
+			)
+
+			winNode := newTestNode(winNodeName, "windows", "", "", "")
+			linuxNode := newTestNode(linuxNodeName, "linux", nodeSubnet, "", "")
+			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
+				Items: []v1.Node{winNode, linuxNode},
+			})
+			fakeClient.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, fmt.Errorf("injected annotation failure")
+			})
+
+			fexec := ovntest.NewFakeExec()
+			err := util.SetExec(fexec)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = config.InitConfig(ctx, fexec, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				ovntest.NewMockOVNClient(goovn.DBNB),
+				ovntest.NewMockOVNClient(goovn.DBSB),
+				record.NewFakeRecorder(10),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			// Windows node subnet must be released again
+			err = m.AddNode(&winNode)
+			Expect(err).To(HaveOccurred())
+			for _, usage := range m.allocator.Usage() {
+				Expect(usage.Allocated).To(BeZero())
+			}
+
+			// Linux node hybrid overlay port must be deleted again
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- " +
+					"--may-exist lsp-add " + linuxNodeName + " int-" + linuxNodeName + " -- " +
+					"lsp-set-addresses int-" + linuxNodeName + " " + nodeHOMAC + " " + nodeHOIP,
+			})
+			fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+				Cmd:    "ovn-nbctl --timeout=15 lsp-list " + linuxNodeName,
+				Output: "29df5ce5-2802-4ee5-891f-4fb27ca776e9 (" + util.K8sPrefix + linuxNodeName + ")",
+			})
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- --if-exists set logical_switch " + linuxNodeName + " other-config:exclude_ips=" + nodeHOIP,
+				"ovn-nbctl --timeout=15 -- --if-exists lsp-del int-" + linuxNodeName,
+			})
+
+			err = m.AddNode(&linuxNode)
+			Expect(err).To(HaveOccurred())
+			Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+			hoNodeCliArg,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("reports health once the existing nodes have been handled, and node failures as events", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				okNodeName   string = "node1"
+				failNodeName string = "node2"
+			)
+
+			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
+				Items: []v1.Node{
+					newTestNode(okNodeName, "windows", "", "", ""),
+					newTestNode(failNodeName, "windows", "", "", ""),
+				},
+			})
+			fakeClient.PrependReactor("patch", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if action.(k8stesting.PatchAction).GetName() == failNodeName {
+					return true, nil, fmt.Errorf("injected annotation failure")
+				}
+				return false, nil, nil
+			})
+
+			fexec := ovntest.NewFakeExec()
+			err := util.SetExec(fexec)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = config.InitConfig(ctx, fexec, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			recorder := record.NewFakeRecorder(10)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				ovntest.NewMockOVNClient(goovn.DBNB),
+				ovntest.NewMockOVNClient(goovn.DBSB),
+				recorder,
+			)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(m.HealthCheck()).To(HaveOccurred())
+
 			f.Start(stopChan)
 			wg.Add(1)
 			go func() {
@@ -219,23 +1007,14 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 				m.Run(stopChan)
 			}()
 
-			Eventually(func() (map[string]string, error) {
-				updatedNode, err := fakeClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
-				if err != nil {
-					return nil, err
-				}
-				return updatedNode.Annotations, nil
-			}, 2).Should(HaveKeyWithValue(types.HybridOverlayDRMAC, nodeHOMAC))
-
-			// Test that deleting the node cleans up the OVN objects
-			fexec.AddFakeCmdsNoOutputNoError([]string{
-				"ovn-nbctl --timeout=15 -- --if-exists lsp-del int-node1",
-			})
-
-			err = fakeClient.CoreV1().Nodes().Delete(context.TODO(), nodeName, *metav1.NewDeleteOptions(0))
-			Expect(err).NotTo(HaveOccurred())
-
-			Eventually(fexec.CalledMatchesExpected, 2).Should(BeTrue(), fexec.ErrorDesc)
+			// The node that can't be annotated doesn't make the controller
+			// unhealthy, but its failure is reported as an event
+			Eventually(m.HealthCheck, 2).Should(Succeed())
+			Eventually(recorder.Events, 2).Should(Receive(And(
+				ContainSubstring("FailedHybridOverlaySetup"),
+				ContainSubstring("injected annotation failure"),
+			)))
+			Consistently(m.HealthCheck).Should(Succeed())
 			return nil
 		}
 
@@ -243,94 +1022,150 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 			app.Name,
 			"-enable-hybrid-overlay",
 			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+			hoNodeCliArg,
 		})
 		Expect(err).NotTo(HaveOccurred())
 	})
 
-	It("handles a Linux node with no annotation but an existing port", func() {
+	It("cleans up after nodes that switch between hybrid overlay and OVN-managed", func() {
 		app.Action = func(ctx *cli.Context) error {
 			const (
-				nodeName   string = "node1"
-				nodeSubnet string = "10.1.2.0/24"
-				nodeHOIP   string = "10.1.2.3"
-				nodeHOMAC  string = "00:00:00:52:19:d2"
+				linuxNodeName  string = "node1"
+				winNodeName    string = "node2"
+				nodeSubnet     string = "10.1.2.0/24"
+				nodeHOIP       string = "10.1.2.3"
+				nodeHOMAC      string = "0a:58:0a:01:02:03"
+				hybridSubnet   string = "11.1.0.0/24"
+				oldWinNodeMAC  string = "0a:58:0a:01:03:03"
+				oldWinNodeIP   string = "10.1.3.3"
+				hybridCIDRSize        = 256
 			)
 
+			// node1 used to be a hybrid overlay node and is now managed by OVN
+			linuxNode := newTestNode(linuxNodeName, "linux", nodeSubnet, hybridSubnet, "")
+			// node2 used to be managed by OVN and is now a hybrid overlay node
+			winNode := newTestNode(winNodeName, "windows", "", "", oldWinNodeMAC)
 			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
-				Items: []v1.Node{
-					newTestNode(nodeName, "linux", nodeSubnet, "", ""),
-				},
+				Items: []v1.Node{linuxNode, winNode},
 			})
 
 			fexec := ovntest.NewFakeExec()
-
 			err := util.SetExec(fexec)
 			Expect(err).NotTo(HaveOccurred())
 			_, err = config.InitConfig(ctx, fexec, nil)
 			Expect(err).NotTo(HaveOccurred())
 			mockOVNNBClient := ovntest.NewMockOVNClient(goovn.DBNB)
-			mockOVNSBClient := ovntest.NewMockOVNClient(goovn.DBSB)
-
-			populatePortAddresses(nodeName, nodeHOMAC, nodeHOIP, mockOVNNBClient)
+			populatePortAddresses(winNodeName, oldWinNodeMAC, oldWinNodeIP, mockOVNNBClient)
 
+			k := &kube.Kube{KClient: fakeClient}
 			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
 			m, err := NewMaster(
-				&kube.Kube{KClient: fakeClient},
+				k,
 				f.Core().V1().Nodes().Informer(),
 				f.Core().V1().Namespaces().Informer(),
 				f.Core().V1().Pods().Informer(),
 				mockOVNNBClient,
-				mockOVNSBClient,
+				ovntest.NewMockOVNClient(goovn.DBSB),
+				record.NewFakeRecorder(10),
 			)
 			Expect(err).NotTo(HaveOccurred())
+			Expect(m.allocator.Usage()[0].Allocated).To(BeEquivalentTo(1))
 
-			f.Start(stopChan)
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				m.Run(stopChan)
-			}()
+			// hybrid overlay -> OVN-managed: subnet released, port created
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- " +
+					"--may-exist lsp-add " + linuxNodeName + " int-" + linuxNodeName + " -- " +
+					"lsp-set-addresses int-" + linuxNodeName + " " + nodeHOMAC + " " + nodeHOIP,
+			})
+			fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+				Cmd:    "ovn-nbctl --timeout=15 lsp-list " + linuxNodeName,
+				Output: "29df5ce5-2802-4ee5-891f-4fb27ca776e9 (" + util.K8sPrefix + linuxNodeName + ")",
+			})
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- --if-exists set logical_switch " + linuxNodeName + " other-config:exclude_ips=" + nodeHOIP,
+			})
 
-			Eventually(func() (map[string]string, error) {
-				updatedNode, err := fakeClient.CoreV1().Nodes().Get(context.TODO(), nodeName, metav1.GetOptions{})
-				if err != nil {
-					return nil, err
-				}
-				return updatedNode.Annotations, nil
-			}, 2).Should(HaveKeyWithValue(types.HybridOverlayDRMAC, nodeHOMAC))
+			err = m.AddNode(&linuxNode)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+			updatedNode, err := k.GetNode(linuxNodeName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updatedNode.Annotations).NotTo(HaveKey(types.HybridOverlayNodeSubnet))
+			Expect(updatedNode.Annotations).To(HaveKeyWithValue(types.HybridOverlayDRMAC, nodeHOMAC))
+			Expect(m.allocator.Usage()[0].Allocated).To(BeZero())
+
+			// OVN-managed -> hybrid overlay: port and DRMAC annotation
+			// deleted, subnet allocated
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 -- --if-exists lsp-del int-" + winNodeName,
+			})
+
+			err = m.AddNode(&winNode)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+			updatedNode, err = k.GetNode(winNodeName)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(updatedNode.Annotations).To(HaveKey(types.HybridOverlayNodeSubnet))
+			Expect(updatedNode.Annotations).NotTo(HaveKey(types.HybridOverlayDRMAC))
+			Expect(m.allocator.Usage()[0]).To(Equal(subnetallocator.RangeUsage{
+				CIDR:      "11.1.0.0/16",
+				Total:     hybridCIDRSize,
+				Allocated: 1,
+			}))
+
+			// Once the hybrid overlay node has set its own DRMAC annotation,
+			// further updates leave the node alone
+			updatedNode.Annotations[types.HybridOverlayDRMAC] = oldWinNodeMAC
+			err = m.AddNode(updatedNode)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
 			return nil
 		}
+
 		err := app.Run([]string{
 			app.Name,
 			"-enable-hybrid-overlay",
 			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+			hoNodeCliArg,
 		})
 		Expect(err).NotTo(HaveOccurred())
 	})
 
-	It("cleans up a Linux node when the OVN hostsubnet annotation is removed", func() {
+	It("copies namespace annotations when a pod is added", func() {
 		app.Action = func(ctx *cli.Context) error {
 			const (
+				nsName     string = "nstest"
+				nsVTEP            = "1.1.1.1"
+				nsExGw            = "2.2.2.2"
 				nodeName   string = "node1"
 				nodeSubnet string = "10.1.2.0/24"
 				nodeHOIP   string = "10.1.2.3"
-				nodeHOMAC  string = "00:00:00:52:19:d2"
+				nodeHOMAC  string = "02:00:00:52:19:d2"
+				pod1Name   string = "pod1"
+				pod1IP     string = "1.2.3.5"
+				pod1CIDR   string = pod1IP + "/24"
+				pod1MAC    string = "aa:bb:cc:dd:ee:ff"
 			)
 
-			fakeClient := fake.NewSimpleClientset(&v1.NodeList{
-				Items: []v1.Node{
-					newTestNode(nodeName, "linux", nodeSubnet, "", nodeHOMAC),
+			ns := &v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					UID:  k8stypes.UID(nsName),
+					Name: nsName,
+					Annotations: map[string]string{
+						types.HybridOverlayVTEP:       nsVTEP,
+						types.HybridOverlayExternalGw: nsExGw,
+					},
 				},
-			})
-
-			fexec := ovntest.NewFakeExec()
-			fexec.AddFakeCmdsNoOutputNoError([]string{
-				"ovn-nbctl --timeout=15 -- --if-exists lsp-del int-node1",
-			})
+				Spec:   v1.NamespaceSpec{},
+				Status: v1.NamespaceStatus{},
+			}
+			fakeClient := fake.NewSimpleClientset([]runtime.Object{
+				ns,
+				createPod(nsName, pod1Name, nodeName, pod1CIDR, pod1MAC),
+				&v1.NodeList{Items: []v1.Node{newTestNode(nodeName, "linux", nodeSubnet, "", nodeHOMAC)}},
+			}...)
 
-			err := util.SetExec(fexec)
-			Expect(err).NotTo(HaveOccurred())
-			_, err = config.InitConfig(ctx, fexec, nil)
+			_, err := config.InitConfig(ctx, nil, nil)
 			Expect(err).NotTo(HaveOccurred())
 
 			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
@@ -343,6 +1178,7 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 				f.Core().V1().Pods().Informer(),
 				mockOVNNBClient,
 				mockOVNSBClient,
+				record.NewFakeRecorder(10),
 			)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -355,24 +1191,20 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 				m.Run(stopChan)
 			}()
 
-			k := &kube.Kube{KClient: fakeClient}
-			updatedNode, err := k.GetNode(nodeName)
-			Expect(err).NotTo(HaveOccurred())
-
-			nodeAnnotator := kube.NewNodeAnnotator(k, updatedNode)
-			util.DeleteNodeHostSubnetAnnotation(nodeAnnotator)
-			err = nodeAnnotator.Run()
-			Expect(err).NotTo(HaveOccurred())
-
-			Eventually(func() (map[string]string, error) {
-				updatedNode, err = k.GetNode(nodeName)
+			Eventually(func() error {
+				pod, err := fakeClient.CoreV1().Pods(nsName).Get(context.TODO(), pod1Name, metav1.GetOptions{})
 				if err != nil {
-					return nil, err
+					return err
 				}
-				return updatedNode.Annotations, nil
-			}, 5).ShouldNot(HaveKey(types.HybridOverlayDRMAC))
+				if pod.Annotations[types.HybridOverlayVTEP] != nsVTEP {
+					return fmt.Errorf("error with annotation %s. expected: %s, got: %s", types.HybridOverlayVTEP, nsVTEP, pod.Annotations[types.HybridOverlayVTEP])
+				}
+				if pod.Annotations[types.HybridOverlayExternalGw] != nsExGw {
+					return fmt.Errorf("error with annotation %s. expected: %s, got: %s", types.HybridOverlayVTEP, nsExGw, pod.Annotations[types.HybridOverlayExternalGw])
+				}
+				return nil
+			}, 2).Should(Succeed())
 
-			Eventually(fexec.CalledMatchesExpected, 2).Should(BeTrue(), fexec.ErrorDesc)
 			return nil
 		}
 
@@ -384,20 +1216,23 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 		Expect(err).NotTo(HaveOccurred())
 	})
 
-	It("copies namespace annotations when a pod is added", func() {
+	It("does not copy an invalid namespace VTEP annotation to a pod", func() {
 		app.Action = func(ctx *cli.Context) error {
 			const (
 				nsName     string = "nstest"
-				nsVTEP            = "1.1.1.1"
+				nsVTEP            = "127.0.0.1"
 				nsExGw            = "2.2.2.2"
 				nodeName   string = "node1"
 				nodeSubnet string = "10.1.2.0/24"
 				nodeHOIP   string = "10.1.2.3"
-				nodeHOMAC  string = "00:00:00:52:19:d2"
+				nodeHOMAC  string = "02:00:00:52:19:d2"
 				pod1Name   string = "pod1"
 				pod1IP     string = "1.2.3.5"
 				pod1CIDR   string = pod1IP + "/24"
 				pod1MAC    string = "aa:bb:cc:dd:ee:ff"
+				pod2Name   string = "pod2"
+				pod2CIDR   string = "1.2.3.6/24"
+				pod2MAC    string = "aa:bb:cc:dd:ee:fe"
 			)
 
 			ns := &v1.Namespace{
@@ -424,6 +1259,7 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
 			mockOVNNBClient := ovntest.NewMockOVNClient(goovn.DBNB)
 			mockOVNSBClient := ovntest.NewMockOVNClient(goovn.DBSB)
+			recorder := record.NewFakeRecorder(10)
 			m, err := NewMaster(
 				&kube.Kube{KClient: fakeClient},
 				f.Core().V1().Nodes().Informer(),
@@ -431,6 +1267,7 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 				f.Core().V1().Pods().Informer(),
 				mockOVNNBClient,
 				mockOVNSBClient,
+				recorder,
 			)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -448,15 +1285,96 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 				if err != nil {
 					return err
 				}
-				if pod.Annotations[types.HybridOverlayVTEP] != nsVTEP {
-					return fmt.Errorf("error with annotation %s. expected: %s, got: %s", types.HybridOverlayVTEP, nsVTEP, pod.Annotations[types.HybridOverlayVTEP])
+				if vtep, ok := pod.Annotations[types.HybridOverlayVTEP]; ok {
+					return fmt.Errorf("unexpected annotation %s: %s", types.HybridOverlayVTEP, vtep)
 				}
 				if pod.Annotations[types.HybridOverlayExternalGw] != nsExGw {
 					return fmt.Errorf("error with annotation %s. expected: %s, got: %s", types.HybridOverlayVTEP, nsExGw, pod.Annotations[types.HybridOverlayExternalGw])
 				}
 				return nil
 			}, 2).Should(Succeed())
+			Eventually(recorder.Events, 2).Should(Receive(HavePrefix(v1.EventTypeWarning + " InvalidHybridOverlayVTEP")))
+
+			// The namespace is only validated once, not for every pod
+			_, err = fakeClient.CoreV1().Pods(nsName).Create(context.TODO(),
+				createPod(nsName, pod2Name, nodeName, pod2CIDR, pod2MAC), metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(func() (map[string]string, error) {
+				pod, err := fakeClient.CoreV1().Pods(nsName).Get(context.TODO(), pod2Name, metav1.GetOptions{})
+				if err != nil {
+					return nil, err
+				}
+				return pod.Annotations, nil
+			}, 2).Should(HaveKeyWithValue(types.HybridOverlayExternalGw, nsExGw))
+			Consistently(recorder.Events).ShouldNot(Receive())
+
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("copies namespace annotations to a pod added before its namespace", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				nsName   string = "nstest"
+				nsVTEP          = "1.1.1.1"
+				nsExGw          = "2.2.2.2"
+				pod1Name string = "pod1"
+			)
+
+			fakeClient := fake.NewSimpleClientset(
+				createPod(nsName, pod1Name, "node1", "1.2.3.5/24", "aa:bb:cc:dd:ee:ff"),
+			)
+
+			_, err := config.InitConfig(ctx, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				f.Core().V1().Pods().Informer(),
+				ovntest.NewMockOVNClient(goovn.DBNB),
+				ovntest.NewMockOVNClient(goovn.DBSB),
+				record.NewFakeRecorder(10),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			f.Start(stopChan)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m.Run(stopChan)
+			}()
+
+			_, err = fakeClient.CoreV1().Namespaces().Create(context.TODO(), &v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: nsName,
+					Annotations: map[string]string{
+						types.HybridOverlayVTEP:       nsVTEP,
+						types.HybridOverlayExternalGw: nsExGw,
+					},
+				},
+			}, metav1.CreateOptions{})
+			Expect(err).NotTo(HaveOccurred())
 
+			Eventually(func() (map[string]string, error) {
+				pod, err := fakeClient.CoreV1().Pods(nsName).Get(context.TODO(), pod1Name, metav1.GetOptions{})
+				if err != nil {
+					return nil, err
+				}
+				return pod.Annotations, nil
+			}, 2).Should(And(
+				HaveKeyWithValue(types.HybridOverlayVTEP, nsVTEP),
+				HaveKeyWithValue(types.HybridOverlayExternalGw, nsExGw),
+			))
 			return nil
 		}
 
@@ -478,7 +1396,7 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 				nsExGwUpdated string = "4.4.4.4"
 				nodeName      string = "node1"
 				nodeSubnet    string = "10.1.2.0/24"
-				nodeHOMAC     string = "00:00:00:52:19:d2"
+				nodeHOMAC     string = "02:00:00:52:19:d2"
 				pod1Name      string = "pod1"
 				pod1IP        string = "1.2.3.5"
 				pod1CIDR      string = pod1IP + "/24"
@@ -517,6 +1435,7 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 				f.Core().V1().Pods().Informer(),
 				mockOVNNBClient,
 				mockOVNSBClient,
+				record.NewFakeRecorder(10),
 			)
 			Expect(err).NotTo(HaveOccurred())
 
@@ -559,4 +1478,140 @@ var _ = Describe("Hybrid SDN Master Operations", func() {
 		})
 		Expect(err).NotTo(HaveOccurred())
 	})
+
+	It("returns an error from AddNamespace when a pod can't be annotated so that it is retried", func() {
+		app.Action = func(ctx *cli.Context) error {
+			const (
+				nsName   string = "nstest"
+				nsVTEP          = "1.1.1.1"
+				nsExGw          = "2.2.2.2"
+				pod1Name string = "pod1"
+			)
+
+			ns := &v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: nsName,
+					Annotations: map[string]string{
+						types.HybridOverlayVTEP:       nsVTEP,
+						types.HybridOverlayExternalGw: nsExGw,
+					},
+				},
+			}
+			fakeClient := fake.NewSimpleClientset(ns,
+				createPod(nsName, pod1Name, "node1", "1.2.3.5/24", "aa:bb:cc:dd:ee:ff"),
+			)
+			failed := false
+			fakeClient.PrependReactor("patch", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if !failed {
+					failed = true
+					return true, nil, fmt.Errorf("injected annotation failure")
+				}
+				return false, nil, nil
+			})
+
+			_, err := config.InitConfig(ctx, nil, nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			f := informers.NewSharedInformerFactory(fakeClient, informer.DefaultResyncInterval)
+			podInformer := f.Core().V1().Pods().Informer()
+			m, err := NewMaster(
+				&kube.Kube{KClient: fakeClient},
+				f.Core().V1().Nodes().Informer(),
+				f.Core().V1().Namespaces().Informer(),
+				podInformer,
+				ovntest.NewMockOVNClient(goovn.DBNB),
+				ovntest.NewMockOVNClient(goovn.DBSB),
+				record.NewFakeRecorder(10),
+			)
+			Expect(err).NotTo(HaveOccurred())
+
+			f.Start(stopChan)
+			Expect(cache.WaitForCacheSync(stopChan, podInformer.HasSynced)).To(BeTrue())
+
+			err = m.AddNamespace(ns)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring(pod1Name))
+
+			err = m.AddNamespace(ns)
+			Expect(err).NotTo(HaveOccurred())
+			pod, err := fakeClient.CoreV1().Pods(nsName).Get(context.TODO(), pod1Name, metav1.GetOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pod.Annotations).To(And(
+				HaveKeyWithValue(types.HybridOverlayVTEP, nsVTEP),
+				HaveKeyWithValue(types.HybridOverlayExternalGw, nsExGw),
+			))
+			return nil
+		}
+
+		err := app.Run([]string{
+			app.Name,
+			"-enable-hybrid-overlay",
+			"-hybrid-overlay-cluster-subnets=" + hybridOverlayClusterCIDR,
+		})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("copies a normalized list of namespace external gateways to a pod", func() {
+		const (
+			nsName   string = "nstest"
+			pod1Name string = "pod1"
+		)
+
+		ns := &v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: nsName,
+				Annotations: map[string]string{
+					types.HybridOverlayExternalGw: "2.2.2.3, 2.2.2.2,2.2.2.3",
+				},
+			},
+		}
+		pod := createPod(nsName, pod1Name, "node1", "1.2.3.5/24", "aa:bb:cc:dd:ee:ff")
+		fakeClient := fake.NewSimpleClientset(ns, pod)
+
+		err := houtil.CopyNamespaceAnnotationsToPod(&kube.Kube{KClient: fakeClient}, ns, pod)
+		Expect(err).NotTo(HaveOccurred())
+		updatedPod, err := fakeClient.CoreV1().Pods(nsName).Get(context.TODO(), pod1Name, metav1.GetOptions{})
+		Expect(err).NotTo(HaveOccurred())
+		// the first gateway stays first, since it is the one the node uses
+		Expect(updatedPod.Annotations).To(HaveKeyWithValue(types.HybridOverlayExternalGw, "2.2.2.3,2.2.2.2"))
+	})
+
+	It("detects reordering of namespace external gateways", func() {
+		newNamespace := func(exGw string) *v1.Namespace {
+			return &v1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "nstest",
+					Annotations: map[string]string{
+						types.HybridOverlayExternalGw: exGw,
+						types.HybridOverlayVTEP:       "1.1.1.1",
+					},
+				},
+			}
+		}
+
+		// nodes only use the first gateway, so a reorder changes the route
+		Expect(nsHybridAnnotationChanged(newNamespace("2.2.2.2,2.2.2.3"), newNamespace("2.2.2.3,2.2.2.2"))).To(BeTrue())
+		Expect(nsHybridAnnotationChanged(newNamespace("2.2.2.2,2.2.2.3"), newNamespace(" 2.2.2.2, 2.2.2.3"))).To(BeFalse())
+		Expect(nsHybridAnnotationChanged(newNamespace("2.2.2.2,2.2.2.3"), newNamespace("2.2.2.2"))).To(BeTrue())
+		Expect(nsHybridAnnotationChanged(newNamespace("2.2.2.2"), newNamespace("not-an-ip"))).To(BeTrue())
+	})
+
+	It("treats invalid namespace annotations that were not copied as converged", func() {
+		ns := &v1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "nstest",
+				Annotations: map[string]string{
+					types.HybridOverlayExternalGw: "not-an-ip",
+					types.HybridOverlayVTEP:       "127.0.0.1",
+				},
+			},
+		}
+		pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "nstest"}}
+		Expect(podHybridAnnotationsCopied(ns, pod)).To(BeTrue())
+
+		ns.Annotations[types.HybridOverlayVTEP] = "1.1.1.1"
+		Expect(podHybridAnnotationsCopied(ns, pod)).To(BeFalse())
+		pod.Annotations = map[string]string{types.HybridOverlayVTEP: "1.1.1.1"}
+		Expect(podHybridAnnotationsCopied(ns, pod)).To(BeTrue())
+	})
 })
@@ -102,10 +102,21 @@ func (n *NodeController) AddPod(pod *kapi.Pod) error {
 	}
 
 	externalGw, ok := pod.Annotations[hotypes.HybridOverlayExternalGw]
-	// validate the external gateway (if any) is a valid IP address
-	if ip := net.ParseIP(externalGw); ok && ip == nil {
-		klog.Warningf("Failed parse a valid external gateway ip address from %v: %v", externalGw, err)
-		return fmt.Errorf("failed to validate a valid external gateway ip address %s: %v", externalGw, err)
+	// validate the external gateways (if any) are valid IP addresses
+	if ok {
+		externalGws, err := houtil.ParseExternalGws(externalGw)
+		if err != nil {
+			klog.Warningf("Failed parse valid external gateway ip addresses from %v: %v", externalGw, err)
+			return fmt.Errorf("failed to validate external gateway ip addresses %s: %v", externalGw, err)
+		}
+		// FIXME: ECMP across multiple external gateways is not supported
+		// by the OVS flows below yet, so only the first gateway listed in
+		// the annotation is used
+		externalGw = externalGws[0].String()
+		if len(externalGws) > 1 {
+			klog.V(5).Infof("Using only external gateway %s of %s for pod %s/%s",
+				externalGw, pod.Annotations[hotypes.HybridOverlayExternalGw], pod.Namespace, pod.Name)
+		}
 	}
 
 	VTEP, ok := pod.Annotations[hotypes.HybridOverlayVTEP]
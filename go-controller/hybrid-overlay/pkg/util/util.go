@@ -3,16 +3,20 @@ package util
 import (
 	"fmt"
 	"net"
+	"strings"
 
 	"github.com/ovn-org/ovn-kubernetes/go-controller/hybrid-overlay/pkg/types"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/factory"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/kube"
+	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/util"
 
 	kapi "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+	utilnet "k8s.io/utils/net"
 )
 
 // ParseHybridOverlayHostSubnet returns the parsed hybrid overlay hostsubnet if
@@ -31,6 +35,29 @@ func ParseHybridOverlayHostSubnet(node *kapi.Node) (*net.IPNet, error) {
 	return subnet, nil
 }
 
+// GetNodeHybridOverlayIfAddrs returns the node logical switch hybrid overlay
+// port address for each IP family in subnets. If subnets contains more than
+// one subnet of a family, only the first one is used.
+func GetNodeHybridOverlayIfAddrs(subnets []*net.IPNet) []net.IP {
+	var ips []net.IP
+	var haveV4, haveV6 bool
+	for _, subnet := range subnets {
+		if utilnet.IsIPv6CIDR(subnet) {
+			if haveV6 {
+				continue
+			}
+			haveV6 = true
+		} else {
+			if haveV4 {
+				continue
+			}
+			haveV4 = true
+		}
+		ips = append(ips, util.GetNodeHybridOverlayIfAddr(subnet).IP)
+	}
+	return ips
+}
+
 // IsHybridOverlayNode returns true if the node has been labeled as a
 // node which does not participate in the ovn-kubernetes overlay network
 func IsHybridOverlayNode(node *kapi.Node) bool {
@@ -81,18 +108,110 @@ func StartNodeWatch(h types.NodeHandler, wf *factory.WatchFactory) {
 	}, nil)
 }
 
-// CopyNamespaceAnnotationsToPod copies annotations from a namespace to a pod
+// ValidateVTEP returns an error if vtep is not an IP address that can be
+// used as a VXLAN tunnel endpoint
+func ValidateVTEP(vtep string) error {
+	ip := net.ParseIP(vtep)
+	if ip == nil {
+		return fmt.Errorf("%q is not a valid IP address", vtep)
+	}
+	if ip.IsLoopback() || ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%q is not a routable IP address", vtep)
+	}
+	return nil
+}
+
+// ValidateDRMAC returns an error if mac can't be used as a node's hybrid
+// overlay distributed router MAC, ie if it isn't a unicast, locally
+// administered MAC address
+func ValidateDRMAC(mac net.HardwareAddr) error {
+	if len(mac) != 6 {
+		return fmt.Errorf("%q is not an EUI-48 MAC address", mac)
+	}
+	if mac[0]&0x01 != 0 {
+		return fmt.Errorf("%s is not a unicast MAC address", mac)
+	}
+	if mac[0]&0x02 == 0 {
+		return fmt.Errorf("%s is not a locally administered MAC address", mac)
+	}
+	return nil
+}
+
+// ParseExternalGws parses the comma-separated list of external gateway IPs
+// in a HybridOverlayExternalGw annotation. The returned list keeps the order
+// of the annotation, since consumers that support only one gateway use the
+// first, and has duplicates removed.
+func ParseExternalGws(gws string) ([]net.IP, error) {
+	var ips []net.IP
+	seen := make(map[string]bool)
+	for _, gw := range strings.Split(gws, ",") {
+		ip := net.ParseIP(strings.TrimSpace(gw))
+		if ip == nil {
+			return nil, fmt.Errorf("%q is not a valid IP address", gw)
+		}
+		if !seen[ip.String()] {
+			seen[ip.String()] = true
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// NormalizeExternalGws returns the canonical form of a HybridOverlayExternalGw
+// annotation value, as parsed by ParseExternalGws
+func NormalizeExternalGws(gws string) (string, error) {
+	ips, err := ParseExternalGws(gws)
+	if err != nil {
+		return "", err
+	}
+	strs := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		strs = append(strs, ip.String())
+	}
+	return strings.Join(strs, ","), nil
+}
+
+// SameExternalGws returns true if a and b hold the same external gateways in
+// the same order, ignoring whitespace and duplicates. The order matters as
+// long as nodes only use the first gateway of the list.
+func SameExternalGws(a, b string) bool {
+	ipsA, errA := ParseExternalGws(a)
+	ipsB, errB := ParseExternalGws(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	if len(ipsA) != len(ipsB) {
+		return false
+	}
+	for i := range ipsA {
+		if !ipsA[i].Equal(ipsB[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// CopyNamespaceAnnotationsToPod copies annotations from a namespace to a pod.
+// The external gateway list is stored on the pod in normalized form. An
+// invalid external gateway or VTEP annotation is not copied; it is up to the
+// namespace handler to report it.
 func CopyNamespaceAnnotationsToPod(k kube.Interface, ns *kapi.Namespace, pod *kapi.Pod) error {
 	nsGw, nsGwExists := ns.Annotations[types.HybridOverlayExternalGw]
 	nsVTEP, nsVTEPExists := ns.Annotations[types.HybridOverlayVTEP]
 	annotator := kube.NewPodAnnotator(k, pod)
 	if nsGwExists {
-		if err := annotator.Set(types.HybridOverlayExternalGw, nsGw); err != nil {
+		if gws, err := NormalizeExternalGws(nsGw); err != nil {
+			klog.V(5).Infof("Not copying namespace %s annotation %s to pod %s: %v",
+				ns.Name, types.HybridOverlayExternalGw, pod.Name, err)
+		} else if err := annotator.Set(types.HybridOverlayExternalGw, gws); err != nil {
 			return err
 		}
 	}
 	if nsVTEPExists {
-		if err := annotator.Set(types.HybridOverlayVTEP, nsVTEP); err != nil {
+		if err := ValidateVTEP(nsVTEP); err != nil {
+			klog.V(5).Infof("Not copying namespace %s annotation %s to pod %s: %v",
+				ns.Name, types.HybridOverlayVTEP, pod.Name, err)
+		} else if err := annotator.Set(types.HybridOverlayVTEP, nsVTEP); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,177 @@
+package util
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	ovntest "github.com/ovn-org/ovn-kubernetes/go-controller/pkg/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNodeHybridOverlayIfAddrs(t *testing.T) {
+	tests := []struct {
+		desc    string
+		subnets []*net.IPNet
+		expIPs  []net.IP
+	}{
+		{
+			desc:    "no subnets",
+			subnets: nil,
+			expIPs:  nil,
+		},
+		{
+			desc:    "single-stack IPv4",
+			subnets: ovntest.MustParseIPNets("10.1.2.0/24"),
+			expIPs:  ovntest.MustParseIPs("10.1.2.3"),
+		},
+		{
+			desc:    "single-stack IPv6",
+			subnets: ovntest.MustParseIPNets("fd00:10:1:2::/64"),
+			expIPs:  ovntest.MustParseIPs("fd00:10:1:2::3"),
+		},
+		{
+			desc:    "dual-stack",
+			subnets: ovntest.MustParseIPNets("10.1.2.0/24", "fd00:10:1:2::/64"),
+			expIPs:  ovntest.MustParseIPs("10.1.2.3", "fd00:10:1:2::3"),
+		},
+		{
+			desc:    "only the first subnet of each family is used",
+			subnets: ovntest.MustParseIPNets("fd00:10:1:2::/64", "10.1.2.0/24", "10.1.3.0/24", "fd00:10:1:3::/64"),
+			expIPs:  ovntest.MustParseIPs("fd00:10:1:2::3", "10.1.2.3"),
+		},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%d:%s", i, tc.desc), func(t *testing.T) {
+			res := GetNodeHybridOverlayIfAddrs(tc.subnets)
+			assert.Equal(t, len(tc.expIPs), len(res))
+			for j := range tc.expIPs {
+				assert.True(t, tc.expIPs[j].Equal(res[j]), "expected %s, got %s", tc.expIPs[j], res[j])
+			}
+		})
+	}
+}
+
+func TestValidateDRMAC(t *testing.T) {
+	tests := []struct {
+		desc   string
+		mac    string
+		errExp bool
+	}{
+		{
+			desc: "derived hybrid overlay MAC",
+			mac:  "0a:58:0a:01:02:03",
+		},
+		{
+			desc: "locally administered unicast MAC",
+			mac:  "02:00:00:00:00:01",
+		},
+		{
+			desc:   "universally administered MAC",
+			mac:    "00:00:00:52:19:d2",
+			errExp: true,
+		},
+		{
+			desc:   "multicast MAC",
+			mac:    "03:00:00:00:00:01",
+			errExp: true,
+		},
+		{
+			desc:   "EUI-64 MAC",
+			mac:    "02:00:00:00:00:00:00:01",
+			errExp: true,
+		},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%d:%s", i, tc.desc), func(t *testing.T) {
+			mac, err := net.ParseMAC(tc.mac)
+			assert.NoError(t, err)
+			err = ValidateDRMAC(mac)
+			if tc.errExp {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseExternalGws(t *testing.T) {
+	tests := []struct {
+		desc   string
+		gws    string
+		expIPs []net.IP
+		errExp bool
+	}{
+		{
+			desc:   "single gateway",
+			gws:    "10.0.0.1",
+			expIPs: ovntest.MustParseIPs("10.0.0.1"),
+		},
+		{
+			desc:   "annotation order is kept and duplicates removed",
+			gws:    "10.0.0.2, 10.0.0.1,10.0.0.2",
+			expIPs: ovntest.MustParseIPs("10.0.0.2", "10.0.0.1"),
+		},
+		{
+			desc:   "invalid gateway",
+			gws:    "10.0.0.1,foo",
+			errExp: true,
+		},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%d:%s", i, tc.desc), func(t *testing.T) {
+			res, err := ParseExternalGws(tc.gws)
+			if tc.errExp {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.expIPs), len(res))
+			for j := range tc.expIPs {
+				assert.True(t, tc.expIPs[j].Equal(res[j]), "expected %s, got %s", tc.expIPs[j], res[j])
+			}
+		})
+	}
+}
+
+func TestSameExternalGws(t *testing.T) {
+	tests := []struct {
+		desc string
+		a, b string
+		same bool
+	}{
+		{
+			desc: "whitespace",
+			a:    "10.0.0.1,10.0.0.2",
+			b:    "10.0.0.1, 10.0.0.2",
+			same: true,
+		},
+		{
+			desc: "different order",
+			a:    "10.0.0.2,10.0.0.1",
+			b:    "10.0.0.1,10.0.0.2",
+		},
+		{
+			desc: "duplicates",
+			a:    "10.0.0.1,10.0.0.1",
+			b:    "10.0.0.1",
+			same: true,
+		},
+		{
+			desc: "different gateways",
+			a:    "10.0.0.1,10.0.0.2",
+			b:    "10.0.0.1,10.0.0.3",
+		},
+		{
+			desc: "different number of gateways",
+			a:    "10.0.0.1,10.0.0.2",
+			b:    "10.0.0.1",
+		},
+	}
+	for i, tc := range tests {
+		t.Run(fmt.Sprintf("%d:%s", i, tc.desc), func(t *testing.T) {
+			assert.Equal(t, tc.same, SameExternalGws(tc.a, tc.b))
+		})
+	}
+}
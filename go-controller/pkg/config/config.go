@@ -82,7 +82,8 @@ var (
 
 	// OVNKubernetesFeatureConfig holds OVN-Kubernetes feature enhancement config file parameters and command-line overrides
 	OVNKubernetesFeature = OVNKubernetesFeatureConfig{
-		EnableEgressIP: true,
+		EnableEgressIP:     true,
+		AddressSetGCDryRun: true,
 	}
 
 	// OvnNorth holds northbound OVN database client and server authentication and location details
@@ -92,7 +93,9 @@ var (
 	OvnSouth OvnAuthConfig
 
 	// Gateway holds node gateway-related parsed config file parameters and command-line overrides
-	Gateway GatewayConfig
+	Gateway = GatewayConfig{
+		ExternalGWHostRefreshInterval: 60,
+	}
 
 	// MasterHA holds master HA related config options.
 	MasterHA = MasterHAConfig{
@@ -103,8 +106,9 @@ var (
 
 	// HybridOverlay holds hybrid overlay feature config options.
 	HybridOverlay = HybridOverlayConfig{
-		RawClusterSubnets: "10.132.0.0/14/23",
-		VXLANPort:         DefaultVXLANPort,
+		RawClusterSubnets:     "10.132.0.0/14/23",
+		VXLANPort:             DefaultVXLANPort,
+		PortReconcileInterval: 300,
 	}
 
 	// NbctlDaemon enables ovn-nbctl to run in daemon mode
@@ -207,6 +211,10 @@ type KubernetesConfig struct {
 // OVNKubernetesFeatureConfig holds OVN-Kubernetes feature enhancement config file parameters and command-line overrides
 type OVNKubernetesFeatureConfig struct {
 	EnableEgressIP bool `gcfg:"enable-egress-ip"`
+	// AddressSetGCDryRun makes the master only log, rather than delete, the
+	// address sets of deleted namespaces and network policies on startup.
+	// It defaults to true.
+	AddressSetGCDryRun bool `gcfg:"address-set-gc-dry-run"`
 }
 
 // GatewayMode holds the node gateway mode
@@ -235,6 +243,9 @@ type GatewayConfig struct {
 	NodeportEnable bool `gcfg:"nodeport"`
 	// DisableSNATMultipleGws sets whether to disable SNAT of egress traffic in namespaces annotated with routing-external-gws
 	DisableSNATMultipleGWs bool `gcfg:"disable-snat-multiple-gws"`
+	// ExternalGWHostRefreshInterval is the interval (in secs) at which hostnames
+	// in routing-external-gws annotations are resolved again
+	ExternalGWHostRefreshInterval int `gcfg:"external-gw-host-refresh-interval"`
 }
 
 // OvnAuthConfig holds client authentication and location details for
@@ -275,6 +286,12 @@ type HybridOverlayConfig struct {
 	ClusterSubnets []CIDRNetworkEntry
 	// VXLANPort holds the VXLAN tunnel UDP port number.
 	VXLANPort uint `gcfg:"hybrid-overlay-vxlan-port"`
+	// PortReconcileInterval is the interval (in secs) at which the master
+	// recreates missing hybrid overlay logical switch ports. 0 disables it.
+	PortReconcileInterval int `gcfg:"port-reconcile-interval"`
+	// DryRun makes the master only log, rather than make, the changes it
+	// would make to hybrid overlay ports, node annotations and subnets.
+	DryRun bool `gcfg:"dry-run"`
 }
 
 // OvnDBScheme describes the OVN database connection transport method
@@ -591,6 +608,12 @@ var OVNK8sFeatureFlags = []cli.Flag{
 		Destination: &cliConfig.OVNKubernetesFeature.EnableEgressIP,
 		Value:       OVNKubernetesFeature.EnableEgressIP,
 	},
+	&cli.BoolFlag{
+		Name:        "address-set-gc-dry-run",
+		Usage:       "Log, rather than delete, the stale address sets of deleted namespaces and network policies on startup. Set to false to delete them.",
+		Destination: &cliConfig.OVNKubernetesFeature.AddressSetGCDryRun,
+		Value:       OVNKubernetesFeature.AddressSetGCDryRun,
+	},
 }
 
 // K8sFlags capture Kubernetes-related options
@@ -789,6 +812,12 @@ var OVNGatewayFlags = []cli.Flag{
 		Usage:       "Disable SNAT for egress traffic with multiple gateways.",
 		Destination: &cliConfig.Gateway.DisableSNATMultipleGWs,
 	},
+	&cli.IntFlag{
+		Name:        "external-gw-host-refresh-interval",
+		Value:       Gateway.ExternalGWHostRefreshInterval,
+		Usage:       "Interval (in secs) at which hostnames in routing-external-gws annotations are resolved again (default: 60)",
+		Destination: &cliConfig.Gateway.ExternalGWHostRefreshInterval,
+	},
 
 	// Deprecated CLI options
 	&cli.BoolFlag{
@@ -849,6 +878,17 @@ var HybridOverlayFlags = []cli.Flag{
 		Usage:       "The UDP port used by the VXLAN protocol for hybrid networks.",
 		Destination: &cliConfig.HybridOverlay.VXLANPort,
 	},
+	&cli.IntFlag{
+		Name:        "hybrid-overlay-port-reconcile-interval",
+		Value:       HybridOverlay.PortReconcileInterval,
+		Usage:       "Interval (in secs) at which missing hybrid overlay logical switch ports are recreated, or 0 to disable (default: 300)",
+		Destination: &cliConfig.HybridOverlay.PortReconcileInterval,
+	},
+	&cli.BoolFlag{
+		Name:        "hybrid-overlay-dry-run",
+		Usage:       "Log, rather than make, the changes the hybrid overlay master would make to logical switch ports, node annotations and subnet allocations.",
+		Destination: &cliConfig.HybridOverlay.DryRun,
+	},
 }
 
 // Flags are general command-line flags. Apps should add these flags to their
@@ -1075,6 +1115,10 @@ func buildGatewayConfig(ctx *cli.Context, cli, file *config) error {
 		}
 	}
 
+	if Gateway.ExternalGWHostRefreshInterval <= 0 {
+		return fmt.Errorf("external gateway host refresh interval %d is invalid", Gateway.ExternalGWHostRefreshInterval)
+	}
+
 	// Options are only valid if Mode is not disabled
 	if Gateway.Mode == GatewayModeDisabled {
 		if Gateway.Interface != "" {
@@ -1151,6 +1195,10 @@ func buildHybridOverlayConfig(ctx *cli.Context, cli, file *config, allSubnets *c
 		if HybridOverlay.VXLANPort > 65535 {
 			return fmt.Errorf("hybrid overlay vxlan port is invalid. The port cannot be larger than 65535")
 		}
+
+		if HybridOverlay.PortReconcileInterval < 0 {
+			return fmt.Errorf("hybrid overlay port reconcile interval %d is invalid", HybridOverlay.PortReconcileInterval)
+		}
 	}
 
 	return nil
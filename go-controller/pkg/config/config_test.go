@@ -172,10 +172,12 @@ interface=eth1
 next-hop=1.3.4.5
 vlan-id=10
 nodeport=false
+external-gw-host-refresh-interval=30
 
 [hybridoverlay]
 enabled=true
 cluster-subnets=11.132.0.0/14/23
+port-reconcile-interval=60
 `
 
 	var newData string
@@ -246,6 +248,7 @@ var _ = Describe("Config Operations", func() {
 			Expect(IPv4Mode).To(Equal(true))
 			Expect(IPv6Mode).To(Equal(false))
 			Expect(HybridOverlay.Enabled).To(Equal(false))
+			Expect(Gateway.ExternalGWHostRefreshInterval).To(Equal(60))
 
 			for _, a := range []OvnAuthConfig{OvnNorth, OvnSouth} {
 				Expect(a.Scheme).To(Equal(OvnDBSchemeUnix))
@@ -502,11 +505,13 @@ var _ = Describe("Config Operations", func() {
 			Expect(Gateway.NextHop).To(Equal("1.3.4.5"))
 			Expect(Gateway.VLANID).To(Equal(uint(10)))
 			Expect(Gateway.NodeportEnable).To(BeFalse())
+			Expect(Gateway.ExternalGWHostRefreshInterval).To(Equal(30))
 
 			Expect(HybridOverlay.Enabled).To(BeTrue())
 			Expect(HybridOverlay.ClusterSubnets).To(Equal([]CIDRNetworkEntry{
 				{ovntest.MustParseIPNet("11.132.0.0/14"), 23},
 			}))
+			Expect(HybridOverlay.PortReconcileInterval).To(Equal(60))
 
 			return nil
 		}
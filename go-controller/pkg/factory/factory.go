@@ -35,6 +35,7 @@ import (
 	informerfactory "k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	listers "k8s.io/client-go/listers/core/v1"
+	netlisters "k8s.io/client-go/listers/networking/v1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 )
@@ -313,7 +314,7 @@ func newInformerLister(oType reflect.Type, sharedInformer cache.SharedIndexInfor
 	case nodeType:
 		return listers.NewNodeLister(sharedInformer.GetIndexer()), nil
 	case policyType:
-		return nil, nil
+		return netlisters.NewNetworkPolicyLister(sharedInformer.GetIndexer()), nil
 	case egressFirewallType:
 		return egressfirewalllister.NewEgressFirewallLister(sharedInformer.GetIndexer()), nil
 	case crdType:
@@ -828,6 +829,12 @@ func (wf *WatchFactory) GetNamespaces() ([]*kapi.Namespace, error) {
 	return namespaceLister.List(labels.Everything())
 }
 
+// GetNetworkPolicies returns a list of network policies in the cluster
+func (wf *WatchFactory) GetNetworkPolicies() ([]*knet.NetworkPolicy, error) {
+	policyLister := wf.informers[policyType].lister.(netlisters.NetworkPolicyLister)
+	return policyLister.List(labels.Everything())
+}
+
 // GetFactory returns the underlying informer factory
 func (wf *WatchFactory) GetFactory() informerfactory.SharedInformerFactory {
 	return wf.iFactory
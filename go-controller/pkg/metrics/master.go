@@ -82,7 +82,67 @@ var MetricMasterLeader = prometheus.NewGauge(prometheus.GaugeOpts{
 	Help:      "Identifies whether the instance of ovnkube-master is a leader(1) or not(0).",
 })
 
+// metricHybridOverlaySubnetAllocations is the number of hybrid overlay host
+// subnets allocated out of each hybrid overlay cluster subnet.
+var metricHybridOverlaySubnetAllocations = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: MetricOvnkubeNamespace,
+	Subsystem: MetricOvnkubeSubsystemMaster,
+	Name:      "hybrid_overlay_subnet_allocations",
+	Help:      "The number of hybrid overlay host subnets allocated out of a hybrid overlay cluster subnet"},
+	[]string{"cidr"},
+)
+
+// metricHybridOverlaySubnetCapacity is the number of hybrid overlay host
+// subnets that can be allocated out of each hybrid overlay cluster subnet.
+var metricHybridOverlaySubnetCapacity = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: MetricOvnkubeNamespace,
+	Subsystem: MetricOvnkubeSubsystemMaster,
+	Name:      "hybrid_overlay_subnet_capacity",
+	Help:      "The number of hybrid overlay host subnets available in a hybrid overlay cluster subnet"},
+	[]string{"cidr"},
+)
+
+// metricHybridOverlayNodeErrors is the number of times setting up a node for
+// the hybrid overlay failed.
+var metricHybridOverlayNodeErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: MetricOvnkubeNamespace,
+	Subsystem: MetricOvnkubeSubsystemMaster,
+	Name:      "hybrid_overlay_node_errors_total",
+	Help:      "The number of times setting up the hybrid overlay subnet or logical switch port of a node failed"},
+)
+
+// metricExternalGatewayNextHops is the number of external gateway next hops
+// programmed for the pods in each namespace.
+var metricExternalGatewayNextHops = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: MetricOvnkubeNamespace,
+	Subsystem: MetricOvnkubeSubsystemMaster,
+	Name:      "external_gateway_nexthops",
+	Help:      "The number of external gateway next hops, from both the namespace annotation and gateway pods, of a namespace"},
+	[]string{"namespace"},
+)
+
+// metricExternalGatewayRouteErrors is the number of times programming an
+// external gateway route for a pod in each namespace failed.
+var metricExternalGatewayRouteErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: MetricOvnkubeNamespace,
+	Subsystem: MetricOvnkubeSubsystemMaster,
+	Name:      "external_gateway_route_errors_total",
+	Help:      "The number of times adding or deleting an external gateway route of a namespace failed"},
+	[]string{"namespace"},
+)
+
+// metricNamespaceAddressSetSize is the number of addresses in the address
+// set of each namespace, sampled periodically.
+var metricNamespaceAddressSetSize = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: MetricOvnkubeNamespace,
+	Subsystem: MetricOvnkubeSubsystemMaster,
+	Name:      "namespace_address_set_size",
+	Help:      "The number of pod IP addresses, of both IP families, in the address set of a namespace"},
+	[]string{"namespace"},
+)
+
 var registerMasterMetricsOnce sync.Once
+var registerHybridOverlayMasterMetricsOnce sync.Once
 var startE2ETimeStampUpdaterOnce sync.Once
 
 // RegisterMasterMetrics registers some ovnkube master metrics with the Prometheus
@@ -129,6 +189,9 @@ func RegisterMasterMetrics(nbClient, sbClient goovn.Client) {
 		util.MetricOvnCliLatency = metricOvnCliLatency
 		prometheus.MustRegister(MetricResourceUpdateCount)
 		prometheus.MustRegister(MetricResourceUpdateLatency)
+		prometheus.MustRegister(metricExternalGatewayNextHops)
+		prometheus.MustRegister(metricExternalGatewayRouteErrors)
+		prometheus.MustRegister(metricNamespaceAddressSetSize)
 		prometheus.MustRegister(prometheus.NewGaugeFunc(
 			prometheus.GaugeOpts{
 				Namespace: MetricOvnkubeNamespace,
@@ -150,6 +213,60 @@ func RegisterMasterMetrics(nbClient, sbClient goovn.Client) {
 	})
 }
 
+// RegisterHybridOverlayMasterMetrics registers the hybrid overlay master
+// metrics with the Prometheus registry
+func RegisterHybridOverlayMasterMetrics() {
+	registerHybridOverlayMasterMetricsOnce.Do(func() {
+		prometheus.MustRegister(metricHybridOverlaySubnetAllocations)
+		prometheus.MustRegister(metricHybridOverlaySubnetCapacity)
+		prometheus.MustRegister(metricHybridOverlayNodeErrors)
+	})
+}
+
+// RecordHybridOverlaySubnetUsage records the number of allocated and total
+// hybrid overlay host subnets of the hybrid overlay cluster subnet cidr
+func RecordHybridOverlaySubnetUsage(cidr string, allocated, total uint64) {
+	metricHybridOverlaySubnetAllocations.WithLabelValues(cidr).Set(float64(allocated))
+	metricHybridOverlaySubnetCapacity.WithLabelValues(cidr).Set(float64(total))
+}
+
+// RecordHybridOverlayNodeError records a failure to set up a node for the
+// hybrid overlay
+func RecordHybridOverlayNodeError() {
+	metricHybridOverlayNodeErrors.Inc()
+}
+
+// RecordExternalGatewayNextHops records the number of external gateway next
+// hops of namespace
+func RecordExternalGatewayNextHops(namespace string, count int) {
+	metricExternalGatewayNextHops.WithLabelValues(namespace).Set(float64(count))
+}
+
+// RecordExternalGatewayRouteError records a failure to add or delete an
+// external gateway route of namespace
+func RecordExternalGatewayRouteError(namespace string) {
+	metricExternalGatewayRouteErrors.WithLabelValues(namespace).Inc()
+}
+
+// DeleteExternalGatewayMetrics removes the external gateway metrics of a
+// namespace that no longer exists
+func DeleteExternalGatewayMetrics(namespace string) {
+	metricExternalGatewayNextHops.DeleteLabelValues(namespace)
+	metricExternalGatewayRouteErrors.DeleteLabelValues(namespace)
+}
+
+// RecordNamespaceAddressSetSize records the number of addresses in the
+// address set of namespace
+func RecordNamespaceAddressSetSize(namespace string, size int) {
+	metricNamespaceAddressSetSize.WithLabelValues(namespace).Set(float64(size))
+}
+
+// DeleteNamespaceAddressSetSize removes the address set size metric of a
+// namespace that no longer exists
+func DeleteNamespaceAddressSetSize(namespace string) {
+	metricNamespaceAddressSetSize.DeleteLabelValues(namespace)
+}
+
 // StartE2ETimeStampMetricUpdater adds a goroutine that updates a "timestamp" value in the
 // nbdb every 30 seconds. This is so we can determine freshness of the database
 func StartE2ETimeStampMetricUpdater(stopChan <-chan struct{}, ovnNBClient goovn.Client) {
@@ -7,6 +7,7 @@ import (
 	"net/http/pprof"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
@@ -174,10 +175,43 @@ func checkPodRunsOnGivenNode(clientset *kubernetes.Clientset, label, k8sNodeName
 	return false, fmt.Errorf("the Pod matching the label %q doesn't exist on this node %s", label, k8sNodeName)
 }
 
-// StartMetricsServer runs the prometheus listener so that OVN K8s metrics can be collected
+var (
+	healthChecksLock sync.Mutex
+	healthChecks     = make(map[string]func() error)
+)
+
+// RegisterHealthCheck makes the metrics server report the result of check at
+// /healthz/<name>, replacing any check previously registered under name. The
+// endpoint returns 200 if check returns nil and 503 otherwise.
+func RegisterHealthCheck(name string, check func() error) {
+	healthChecksLock.Lock()
+	defer healthChecksLock.Unlock()
+	healthChecks[name] = check
+}
+
+// handleHealthCheck serves the health checks registered with RegisterHealthCheck
+func handleHealthCheck(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/healthz/")
+	healthChecksLock.Lock()
+	check, ok := healthChecks[name]
+	healthChecksLock.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if err := check(); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// StartMetricsServer runs the prometheus listener so that OVN K8s metrics can be collected.
+// It also serves the health checks registered with RegisterHealthCheck.
 func StartMetricsServer(bindAddress string, enablePprof bool) {
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/healthz/", handleHealthCheck)
 
 	if enablePprof {
 		mux.HandleFunc("/debug/pprof/", pprof.Index)
@@ -7,6 +7,7 @@ import (
 	"strings"
 	"sync"
 
+	goovn "github.com/ebay/go-ovn"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/util"
 
@@ -36,6 +37,13 @@ type AddressSetFactory interface {
 	// factory's backing store. SHOULD NOT BE CALLED for any address set
 	// for which an AddressSet object has been created.
 	DestroyAddressSetInBackingStore(name string) error
+	// PopulateCache reads the existing address sets from the backing store
+	// so that NewAddressSet doesn't have to look each one up, until
+	// InvalidateCache is called
+	PopulateCache() error
+	// InvalidateCache drops any cached knowledge of the backing store, so
+	// that it is queried again
+	InvalidateCache()
 }
 
 // AddressSet is an interface for address set objects
@@ -46,17 +54,31 @@ type AddressSet interface {
 	GetIPv6HashName() string
 	// GetName returns the descriptive name of the address set
 	GetName() string
+	// Size returns the number of IPs in the v4 and v6 address sets together
+	Size() int
 	AddIPs(ip []net.IP) error
 	DeleteIPs(ip []net.IP) error
+	// ReconcileIPs updates the address set to contain exactly the given IPs,
+	// adding and removing only those IPs that differ from the database
+	ReconcileIPs(ip []net.IP) error
 	Destroy() error
 }
 
-type ovnAddressSetFactory struct{}
+type ovnAddressSetFactory struct {
+	cache *addressSetCache
+	// nbClient, if set, is used to list the existing address sets
+	// instead of parsing ovn-nbctl output
+	nbClient goovn.Client
+}
 
 // NewOvnAddressSetFactory creates a new AddressSetFactory backed by
-// address set objects that execute OVN commands
-func NewOvnAddressSetFactory() AddressSetFactory {
-	return &ovnAddressSetFactory{}
+// address set objects that execute OVN commands. If nbClient is not nil,
+// it is used to read the address sets from the northbound database.
+func NewOvnAddressSetFactory(nbClient goovn.Client) AddressSetFactory {
+	return &ovnAddressSetFactory{
+		cache:    &addressSetCache{},
+		nbClient: nbClient,
+	}
 }
 
 // ovnAddressSetFactory implements the AddressSetFactory interface
@@ -64,52 +86,118 @@ var _ AddressSetFactory = &ovnAddressSetFactory{}
 
 // NewAddressSet returns a new address set object
 func (asf *ovnAddressSetFactory) NewAddressSet(name string, ips []net.IP) (AddressSet, error) {
-	return newOvnAddressSets(name, ips)
+	return newOvnAddressSets(name, ips, asf.cache)
 }
 
-// ForEachAddressSet will pass the unhashed address set name, namespace name
-// and the first suffix in the name to the 'iteratorFn' for every address_set in
-// OVN. (Unhashed address set names are of the form namespaceName[.suffix1.suffix2. .suffixN])
-func (asf *ovnAddressSetFactory) ForEachAddressSet(iteratorFn AddressSetIterFunc) error {
-	output, stderr, err := util.RunOVNNbctl("--format=csv", "--data=bare", "--no-heading",
-		"--columns=external_ids", "find", "address_set")
+// addressSetRow is the part of an OVN address_set row that
+// ForEachAddressSet cares about
+type addressSetRow struct {
+	uuid     string
+	hashName string
+	// name is the unhashed name from the "name" external ID, if any
+	name string
+}
+
+// listAddressSetsViaNbctl returns all address sets in OVN by parsing the
+// output of ovn-nbctl
+func listAddressSetsViaNbctl() ([]addressSetRow, error) {
+	output, stderr, err := util.RunOVNNbctlWithRetry("--format=csv", "--data=bare", "--no-heading",
+		"--columns=_uuid,name,external_ids", "find", "address_set")
 	if err != nil {
-		return fmt.Errorf("error reading address sets: "+
+		return nil, fmt.Errorf("error reading address sets: "+
 			"stdout: %q, stderr: %q err: %v", output, stderr, err)
 	}
 
-	processedAddressSets := sets.String{}
+	var rows []addressSetRow
 	for _, line := range strings.Split(output, "\n") {
 		parts := strings.Split(line, ",")
-		if len(parts) != 2 {
+		if len(parts) != 3 {
 			continue
 		}
-		for _, externalID := range strings.Fields(parts[1]) {
-			if !strings.HasPrefix(externalID, "name=") {
-				continue
-			}
-			// Remove the suffix from the address set name and normalize
-			addrSetName := truncateSuffixFromAddressSet(externalID[5:])
-			if processedAddressSets.Has(addrSetName) {
-				// We have already processed the address set. In case of dual stack we will have _v4 and _v6
-				// suffixes for address sets. Since we are normalizing these two address sets through this API
-				// we will process only one normalized address set name.
+		row := addressSetRow{uuid: parts[0], hashName: parts[1]}
+		for _, externalID := range strings.Fields(parts[2]) {
+			if strings.HasPrefix(externalID, "name=") {
+				row.name = externalID[5:]
 				break
 			}
-			processedAddressSets.Insert(addrSetName)
-			names := strings.Split(addrSetName, ".")
-			addrSetNamespace := names[0]
-			nameSuffix := ""
-			if len(names) >= 2 {
-				nameSuffix = names[1]
-			}
-			iteratorFn(addrSetName, addrSetNamespace, nameSuffix)
-			break
 		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// listAddressSetsViaDB returns all address sets in OVN by querying the
+// northbound database's Address_Set table
+func (asf *ovnAddressSetFactory) listAddressSetsViaDB() ([]addressSetRow, error) {
+	addrSets, err := asf.nbClient.ASList()
+	if err != nil {
+		return nil, fmt.Errorf("error reading address sets: %v", err)
+	}
+
+	rows := make([]addressSetRow, 0, len(addrSets))
+	for _, as := range addrSets {
+		row := addressSetRow{uuid: as.UUID, hashName: as.Name}
+		if name, ok := as.ExternalID["name"].(string); ok {
+			row.name = name
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// listAddressSets returns all address sets in OVN
+func (asf *ovnAddressSetFactory) listAddressSets() ([]addressSetRow, error) {
+	if asf.nbClient != nil {
+		return asf.listAddressSetsViaDB()
+	}
+	return listAddressSetsViaNbctl()
+}
+
+// ForEachAddressSet will pass the unhashed address set name, namespace name
+// and the first suffix in the name to the 'iteratorFn' for every address_set in
+// OVN. (Unhashed address set names are of the form namespaceName[.suffix1.suffix2. .suffixN])
+func (asf *ovnAddressSetFactory) ForEachAddressSet(iteratorFn AddressSetIterFunc) error {
+	rows, err := asf.listAddressSets()
+	if err != nil {
+		return err
+	}
+
+	processedAddressSets := sets.String{}
+	for _, row := range rows {
+		if row.name == "" {
+			continue
+		}
+		// Remove the suffix from the address set name and normalize
+		addrSetName := truncateSuffixFromAddressSet(row.name)
+		if processedAddressSets.Has(addrSetName) {
+			// We have already processed the address set. In case of dual stack we will have _v4 and _v6
+			// suffixes for address sets. Since we are normalizing these two address sets through this API
+			// we will process only one normalized address set name.
+			continue
+		}
+		processedAddressSets.Insert(addrSetName)
+		names := strings.Split(addrSetName, ".")
+		addrSetNamespace := names[0]
+		nameSuffix := ""
+		if len(names) >= 2 {
+			nameSuffix = names[1]
+		}
+		iteratorFn(addrSetName, addrSetNamespace, nameSuffix)
 	}
 	return nil
 }
 
+// PopulateCache fills the factory's cache with the address sets in OVN
+func (asf *ovnAddressSetFactory) PopulateCache() error {
+	return asf.cache.populate(asf.listAddressSets)
+}
+
+// InvalidateCache forgets the cached address sets; until PopulateCache is
+// called again, address set creation queries OVN for existing sets.
+func (asf *ovnAddressSetFactory) InvalidateCache() {
+	asf.cache.invalidate()
+}
+
 func truncateSuffixFromAddressSet(asName string) string {
 	// Legacy address set names will not have v4 or v6 suffixes.
 	// truncate them for the new ones
@@ -127,33 +215,114 @@ func (asf *ovnAddressSetFactory) DestroyAddressSetInBackingStore(name string) er
 	// will not have v4 and v6 suffix as they were same as namespace name. Hence we will always try to destroy
 	// the address set with raw name(namespace name), v4 name and v6 name.  The method destroyAddressSet uses
 	// --if-exists parameter which will take care of deleting the address set only if it exists.
-	err := destroyAddressSet(name)
+	err := destroyAddressSet(name, asf.cache)
 	if err != nil {
 		return err
 	}
-	err = destroyAddressSet(getIPv4ASName(name))
+	err = destroyAddressSet(getIPv4ASName(name), asf.cache)
 	if err != nil {
 		return err
 	}
-	err = destroyAddressSet(getIPv6ASName(name))
+	err = destroyAddressSet(getIPv6ASName(name), asf.cache)
 	return err
 }
 
-func destroyAddressSet(name string) error {
+func destroyAddressSet(name string, cache *addressSetCache) error {
 	hashName := hashedAddressSet(name)
-	_, stderr, err := util.RunOVNNbctl("--if-exists", "destroy", "address_set", hashName)
+	_, stderr, err := util.RunOVNNbctlWithRetry("--if-exists", "destroy", "address_set", hashName)
 	if err != nil {
+		cache.invalidate()
 		return fmt.Errorf("failed to destroy address set %q, stderr: %q, (%v)",
 			hashName, stderr, err)
 	}
+	cache.delete(hashName)
 	return nil
 }
 
+// addressSetCache maps the hashed names of the address sets in OVN to their
+// UUIDs, so that creating an address set doesn't need to look it up first.
+// It is only consulted once populated by PopulateCache, and is
+// invalidated whenever creating or destroying an address set fails since the
+// database may then have changed underneath it. A nil cache is never populated.
+type addressSetCache struct {
+	sync.Mutex
+	// uuids is nil while the cache is not populated
+	uuids map[string]string
+}
+
+// populate replaces the contents of the cache with the address sets returned
+// by list. The cache stays locked while listing, so that an address set that
+// is created or destroyed meanwhile can't be lost by populating the cache from
+// a list taken before the change.
+func (c *addressSetCache) populate(list func() ([]addressSetRow, error)) error {
+	if c == nil {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	rows, err := list()
+	if err != nil {
+		c.uuids = nil
+		return err
+	}
+	c.uuids = make(map[string]string, len(rows))
+	for _, row := range rows {
+		c.uuids[row.hashName] = row.uuid
+	}
+	return nil
+}
+
+func (c *addressSetCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.uuids = nil
+}
+
+// lookup returns the UUID of the given address set ("" if it does not exist)
+// and whether the cache was able to answer at all
+func (c *addressSetCache) lookup(hashName string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.uuids == nil {
+		return "", false
+	}
+	return c.uuids[hashName], true
+}
+
+func (c *addressSetCache) add(hashName, uuid string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.uuids != nil {
+		c.uuids[hashName] = uuid
+	}
+}
+
+func (c *addressSetCache) delete(hashName string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	if c.uuids != nil {
+		delete(c.uuids, hashName)
+	}
+}
+
 type ovnAddressSet struct {
 	name     string
 	hashName string
 	uuid     string
 	ips      map[string]net.IP
+	cache    *addressSetCache
 }
 
 type ovnAddressSets struct {
@@ -175,7 +344,7 @@ func asDetail(as *ovnAddressSet) string {
 	return fmt.Sprintf("%s/%s/%s", as.uuid, as.name, as.hashName)
 }
 
-func newOvnAddressSets(name string, ips []net.IP) (*ovnAddressSets, error) {
+func newOvnAddressSets(name string, ips []net.IP, cache *addressSetCache) (*ovnAddressSets, error) {
 	var (
 		v4set, v6set *ovnAddressSet
 		err          error
@@ -191,13 +360,13 @@ func newOvnAddressSets(name string, ips []net.IP) (*ovnAddressSets, error) {
 		}
 	}
 	if config.IPv4Mode {
-		v4set, err = newOvnAddressSet(getIPv4ASName(name), v4IPs)
+		v4set, err = newOvnAddressSet(getIPv4ASName(name), v4IPs, cache)
 		if err != nil {
 			return nil, err
 		}
 	}
 	if config.IPv6Mode {
-		v6set, err = newOvnAddressSet(getIPv6ASName(name), v6IPs)
+		v6set, err = newOvnAddressSet(getIPv6ASName(name), v6IPs, cache)
 		if err != nil {
 			return nil, err
 		}
@@ -205,29 +374,38 @@ func newOvnAddressSets(name string, ips []net.IP) (*ovnAddressSets, error) {
 	return &ovnAddressSets{name: name, ipv4: v4set, ipv6: v6set}, nil
 }
 
-func newOvnAddressSet(name string, ips []net.IP) (*ovnAddressSet, error) {
+func newOvnAddressSet(name string, ips []net.IP, cache *addressSetCache) (*ovnAddressSet, error) {
 	as := &ovnAddressSet{
 		name:     name,
 		hashName: hashedAddressSet(name),
 		ips:      make(map[string]net.IP),
+		cache:    cache,
 	}
 	for _, ip := range ips {
 		as.ips[ip.String()] = ip
 	}
 
-	uuid, stderr, err := util.RunOVNNbctl("--data=bare",
-		"--no-heading", "--columns=_uuid", "find", "address_set",
-		"name="+as.hashName)
-	if err != nil {
-		return nil, fmt.Errorf("find failed to get address set %q, stderr: %q (%v)",
-			as.name, stderr, err)
+	uuid, cached := cache.lookup(as.hashName)
+	if !cached {
+		var stderr string
+		var err error
+		uuid, stderr, err = util.RunOVNNbctlWithRetry("--data=bare",
+			"--no-heading", "--columns=_uuid", "find", "address_set",
+			"name="+as.hashName)
+		if err != nil {
+			return nil, fmt.Errorf("find failed to get address set %q, stderr: %q (%v)",
+				as.name, stderr, err)
+		}
 	}
 	as.uuid = uuid
 
 	if uuid != "" {
-		klog.V(5).Infof("New(%s) already exists; updating IPs", asDetail(as))
-		// ovnAddressSet already exists in the database; just update IPs
-		if err := as.setOrClear(); err != nil {
+		klog.V(5).Infof("New(%s) already exists; reconciling IPs", asDetail(as))
+		// ovnAddressSet already exists in the database; only add and remove
+		// the IPs that differ, so that OVN doesn't recompute flows for the
+		// addresses that stay
+		if err := as.reconcileIPs(ips); err != nil {
+			cache.invalidate()
 			return nil, err
 		}
 	} else {
@@ -242,11 +420,15 @@ func newOvnAddressSet(name string, ips []net.IP) (*ovnAddressSet, error) {
 		if len(joinedIPs) > 0 {
 			args = append(args, "addresses="+joinedIPs)
 		}
-		as.uuid, stderr, err = util.RunOVNNbctl(args...)
+		var stderr string
+		var err error
+		as.uuid, stderr, err = util.RunOVNNbctlWithRetry(args...)
 		if err != nil {
+			cache.invalidate()
 			return nil, fmt.Errorf("failed to create address set %q, stderr: %q (%v)",
 				asDetail(as), stderr, err)
 		}
+		cache.add(as.hashName, as.uuid)
 	}
 
 	klog.V(5).Infof("New(%s) with %v", asDetail(as), ips)
@@ -272,38 +454,143 @@ func (as *ovnAddressSets) GetName() string {
 	return as.name
 }
 
+func (as *ovnAddressSets) Size() int {
+	as.RLock()
+	defer as.RUnlock()
+
+	size := 0
+	if as.ipv4 != nil {
+		size += len(as.ipv4.ips)
+	}
+	if as.ipv6 != nil {
+		size += len(as.ipv6.ips)
+	}
+	return size
+}
+
+// AddIPs adds the given IPs to the v4 and v6 address sets using a single
+// ovn-nbctl transaction
 func (as *ovnAddressSets) AddIPs(ips []net.IP) error {
-	var err error
 	as.Lock()
 	defer as.Unlock()
 
-	for _, ip := range ips {
-		if utilnet.IsIPv6(ip) {
-			err = as.ipv6.addIP(ip)
-		} else {
-			err = as.ipv4.addIP(ip)
-		}
-		if err != nil {
-			return err
-		}
+	if err := as.validateIPs(ips); err != nil {
+		return fmt.Errorf("failed to add IPs %v to address set %q: %v", ips, as.name, err)
+	}
+	v4IPs, v6IPs := splitIPsByFamily(ips)
+	v4Args := as.ipv4.addIPsArgs(v4IPs)
+	v6Args := as.ipv6.addIPsArgs(v6IPs)
+	if err := runAddressSetTransaction(v4Args, v6Args); err != nil {
+		return fmt.Errorf("failed to add IPs %v to address set %q: %v", ips, as.name, err)
 	}
+	as.ipv4.recordIPs(v4IPs)
+	as.ipv6.recordIPs(v6IPs)
 	return nil
 }
 
+// DeleteIPs removes the given IPs from the v4 and v6 address sets using a
+// single ovn-nbctl transaction
 func (as *ovnAddressSets) DeleteIPs(ips []net.IP) error {
-	var err error
 	as.Lock()
 	defer as.Unlock()
 
+	if err := as.validateIPs(ips); err != nil {
+		return fmt.Errorf("failed to remove IPs %v from address set %q: %v", ips, as.name, err)
+	}
+	v4IPs, v6IPs := splitIPsByFamily(ips)
+	v4Args := as.ipv4.deleteIPsArgs(v4IPs)
+	v6Args := as.ipv6.deleteIPsArgs(v6IPs)
+	if err := runAddressSetTransaction(v4Args, v6Args); err != nil {
+		return fmt.Errorf("failed to remove IPs %v from address set %q: %v", ips, as.name, err)
+	}
+	as.ipv4.forgetIPs(v4IPs)
+	as.ipv6.forgetIPs(v6IPs)
+	return nil
+}
+
+// ReconcileIPs updates the v4 and v6 address sets to contain exactly the
+// given IPs. It reads the current addresses from the database and adds and
+// removes only the IPs that differ, in a single ovn-nbctl transaction, so
+// that OVN doesn't recompute flows for unchanged addresses.
+func (as *ovnAddressSets) ReconcileIPs(ips []net.IP) error {
+	as.Lock()
+	defer as.Unlock()
+
+	if err := as.validateIPs(ips); err != nil {
+		return fmt.Errorf("failed to reconcile IPs %v of address set %q: %v", ips, as.name, err)
+	}
+	v4IPs, v6IPs := splitIPsByFamily(ips)
+	var commands [][]string
+	for _, family := range []struct {
+		as  *ovnAddressSet
+		ips []net.IP
+	}{{as.ipv4, v4IPs}, {as.ipv6, v6IPs}} {
+		if family.as == nil {
+			continue
+		}
+		current, err := family.as.getAddresses()
+		if err != nil {
+			return fmt.Errorf("failed to reconcile IPs %v of address set %q: %v", ips, as.name, err)
+		}
+		commands = append(commands, family.as.reconcileIPsArgs(current, family.ips)...)
+	}
+	if err := runAddressSetTransaction(commands...); err != nil {
+		return fmt.Errorf("failed to reconcile IPs %v of address set %q: %v", ips, as.name, err)
+	}
+	as.ipv4.replaceIPs(v4IPs)
+	as.ipv6.replaceIPs(v6IPs)
+	return nil
+}
+
+// validateIPs returns an error if any of ips is not a valid IP address, or
+// is of an IP family that the address set has no OVN address set for
+func (as *ovnAddressSets) validateIPs(ips []net.IP) error {
+	for _, ip := range ips {
+		if ip.To16() == nil {
+			return fmt.Errorf("invalid IP address %q", ip)
+		}
+		if utilnet.IsIPv6(ip) {
+			if as.ipv6 == nil {
+				return fmt.Errorf("IPv6 address %s can't be used in an IPv4-only address set", ip)
+			}
+		} else if as.ipv4 == nil {
+			return fmt.Errorf("IPv4 address %s can't be used in an IPv6-only address set", ip)
+		}
+	}
+	return nil
+}
+
+func splitIPsByFamily(ips []net.IP) ([]net.IP, []net.IP) {
+	var v4IPs, v6IPs []net.IP
 	for _, ip := range ips {
 		if utilnet.IsIPv6(ip) {
-			err = as.ipv6.deleteIP(ip)
+			v6IPs = append(v6IPs, ip)
 		} else {
-			err = as.ipv4.deleteIP(ip)
+			v4IPs = append(v4IPs, ip)
 		}
-		if err != nil {
-			return err
+	}
+	return v4IPs, v6IPs
+}
+
+// runAddressSetTransaction runs the given ovn-nbctl commands, skipping empty
+// ones, as a single ovn-nbctl transaction
+func runAddressSetTransaction(commands ...[]string) error {
+	var args []string
+	for _, command := range commands {
+		if len(command) == 0 {
+			continue
 		}
+		if len(args) > 0 {
+			args = append(args, "--")
+		}
+		args = append(args, command...)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	_, stderr, err := util.RunOVNNbctlWithRetry(args...)
+	if err != nil {
+		return fmt.Errorf("stderr: %q (%v)", stderr, err)
 	}
 	return nil
 }
@@ -336,69 +623,123 @@ func (as *ovnAddressSet) joinIPs() string {
 	return strings.Join(list, " ")
 }
 
-// setOrClear updates the OVN database with the address set's addresses or
-// clears the address set if there are no addresses in the address set
-func (as *ovnAddressSet) setOrClear() error {
-	joinedIPs := as.joinIPs()
-	if len(joinedIPs) > 0 {
-		_, stderr, err := util.RunOVNNbctl("set", "address_set", as.uuid, "addresses="+joinedIPs)
-		if err != nil {
-			return fmt.Errorf("failed to set address set %q, stderr: %q (%v)",
-				asDetail(as), stderr, err)
+// addIPsArgs returns the ovn-nbctl command to add those of ips that aren't
+// in the address set yet, or nil if there are none
+func (as *ovnAddressSet) addIPsArgs(ips []net.IP) []string {
+	var args []string
+	for _, ip := range ips {
+		ipStr := ip.String()
+		if _, ok := as.ips[ipStr]; ok {
+			continue
 		}
-	} else {
-		_, stderr, err := util.RunOVNNbctl("clear", "address_set", as.uuid, "addresses")
-		if err != nil {
-			return fmt.Errorf("failed to clear address set %q, stderr: %q (%v)",
-				asDetail(as), stderr, err)
+		if args == nil {
+			args = []string{"add", "address_set", as.uuid, "addresses"}
 		}
+		klog.V(5).Infof("(%s) adding IP %s to address set", asDetail(as), ipStr)
+		args = append(args, `"`+ipStr+`"`)
 	}
-	return nil
+	return args
 }
 
-func (as *ovnAddressSet) addIP(ip net.IP) error {
-	ipStr := ip.String()
-	if _, ok := as.ips[ipStr]; ok {
-		return nil
+// deleteIPsArgs returns the ovn-nbctl command to remove those of ips that are
+// in the address set, or nil if there are none
+func (as *ovnAddressSet) deleteIPsArgs(ips []net.IP) []string {
+	var args []string
+	for _, ip := range ips {
+		ipStr := ip.String()
+		if _, ok := as.ips[ipStr]; !ok {
+			continue
+		}
+		if args == nil {
+			args = []string{"remove", "address_set", as.uuid, "addresses"}
+		}
+		klog.V(5).Infof("(%s) deleting IP %s from address set", asDetail(as), ipStr)
+		args = append(args, `"`+ipStr+`"`)
 	}
+	return args
+}
 
-	klog.V(5).Infof("(%s) adding IP %s to address set", asDetail(as), ipStr)
-
-	_, stderr, err := util.RunOVNNbctl("add", "address_set", as.uuid, "addresses", `"`+ipStr+`"`)
+// getAddresses returns the addresses of the address set in the database
+func (as *ovnAddressSet) getAddresses() (sets.String, error) {
+	output, stderr, err := util.RunOVNNbctlWithRetry("--data=bare", "--no-heading",
+		"--columns=addresses", "list", "address_set", as.uuid)
 	if err != nil {
-		return fmt.Errorf("failed to add IP %q to address set %q, stderr: %q (%v)",
-			ip, asDetail(as), stderr, err)
+		return nil, fmt.Errorf("failed to get addresses of address set %q, stderr: %q (%v)",
+			asDetail(as), stderr, err)
 	}
+	return sets.NewString(strings.Fields(output)...), nil
+}
 
-	as.ips[ip.String()] = ip
-	return nil
+// reconcileIPsArgs returns the ovn-nbctl commands to change the address set's
+// addresses from current to ips
+func (as *ovnAddressSet) reconcileIPsArgs(current sets.String, ips []net.IP) [][]string {
+	desired := sets.NewString()
+	for _, ip := range ips {
+		desired.Insert(ip.String())
+	}
+	var commands [][]string
+	if toAdd := desired.Difference(current); toAdd.Len() > 0 {
+		klog.V(5).Infof("(%s) adding IPs %v to address set", asDetail(as), toAdd.List())
+		args := []string{"add", "address_set", as.uuid, "addresses"}
+		for _, ipStr := range toAdd.List() {
+			args = append(args, `"`+ipStr+`"`)
+		}
+		commands = append(commands, args)
+	}
+	if toDelete := current.Difference(desired); toDelete.Len() > 0 {
+		klog.V(5).Infof("(%s) deleting IPs %v from address set", asDetail(as), toDelete.List())
+		args := []string{"remove", "address_set", as.uuid, "addresses"}
+		for _, ipStr := range toDelete.List() {
+			args = append(args, `"`+ipStr+`"`)
+		}
+		commands = append(commands, args)
+	}
+	return commands
 }
 
-func (as *ovnAddressSet) deleteIP(ip net.IP) error {
-	ipStr := ip.String()
-	if _, ok := as.ips[ipStr]; !ok {
-		return nil
+// reconcileIPs updates the address set in the database to contain exactly
+// ips, adding and removing only those that differ from its current addresses
+func (as *ovnAddressSet) reconcileIPs(ips []net.IP) error {
+	current, err := as.getAddresses()
+	if err != nil {
+		return err
 	}
+	if err := runAddressSetTransaction(as.reconcileIPsArgs(current, ips)...); err != nil {
+		return fmt.Errorf("failed to reconcile address set %q: %v", asDetail(as), err)
+	}
+	return nil
+}
 
-	klog.V(5).Infof("(%s) deleting IP %s from address set", asDetail(as), ipStr)
+// replaceIPs replaces the address set's record of its IPs with ips
+func (as *ovnAddressSet) replaceIPs(ips []net.IP) {
+	if as == nil {
+		return
+	}
+	as.ips = make(map[string]net.IP, len(ips))
+	as.recordIPs(ips)
+}
 
-	_, stderr, err := util.RunOVNNbctl("remove", "address_set", as.uuid, "addresses", `"`+ipStr+`"`)
-	if err != nil {
-		return fmt.Errorf("failed to remove IP %q from address set %q, stderr: %q (%v)",
-			ip, asDetail(as), stderr, err)
+func (as *ovnAddressSet) recordIPs(ips []net.IP) {
+	for _, ip := range ips {
+		as.ips[ip.String()] = ip
 	}
+}
 
-	delete(as.ips, ipStr)
-	return nil
+func (as *ovnAddressSet) forgetIPs(ips []net.IP) {
+	for _, ip := range ips {
+		delete(as.ips, ip.String())
+	}
 }
 
 func (as *ovnAddressSet) destroy() error {
 	klog.V(5).Infof("destroy(%s)", asDetail(as))
-	_, stderr, err := util.RunOVNNbctl("--if-exists", "destroy", "address_set", as.uuid)
+	_, stderr, err := util.RunOVNNbctlWithRetry("--if-exists", "destroy", "address_set", as.uuid)
 	if err != nil {
+		as.cache.invalidate()
 		return fmt.Errorf("failed to destroy address set %q, stderr: %q, (%v)",
 			asDetail(as), stderr, err)
 	}
+	as.cache.delete(as.hashName)
 	as.ips = nil
 	return nil
 }
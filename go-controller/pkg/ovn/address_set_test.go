@@ -3,7 +3,10 @@ package ovn
 import (
 	"fmt"
 	"net"
+	"strings"
+	"testing"
 
+	goovn "github.com/ebay/go-ovn"
 	"github.com/urfave/cli/v2"
 
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
@@ -24,6 +27,16 @@ func (asn *testAddressSetName) makeName() string {
 	return fmt.Sprintf("%s.%s.%s", asn.namespace, asn.suffix1, asn.suffix2)
 }
 
+// asListClient is a goovn.Client that only implements ASList
+type asListClient struct {
+	goovn.Client
+	addressSets []*goovn.AddressSet
+}
+
+func (c *asListClient) ASList() ([]*goovn.AddressSet, error) {
+	return c.addressSets, nil
+}
+
 var _ = Describe("OVN Address Set operations", func() {
 	var (
 		app       *cli.App
@@ -43,7 +56,7 @@ var _ = Describe("OVN Address Set operations", func() {
 		err := util.SetExec(fexec)
 		Expect(err).NotTo(HaveOccurred())
 
-		asFactory = NewOvnAddressSetFactory()
+		asFactory = NewOvnAddressSetFactory(nil)
 	})
 
 	Context("when iterating address sets", func() {
@@ -71,10 +84,10 @@ var _ = Describe("OVN Address Set operations", func() {
 				var namespacesRes string
 				for _, n := range namespaces {
 					name := n.makeName()
-					namespacesRes += fmt.Sprintf("%s,name=%s\n", hashedAddressSet(name), name)
+					namespacesRes += fmt.Sprintf("%s,%s,name=%s\n", fakeUUID, hashedAddressSet(name), name)
 				}
 				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
-					Cmd:    "ovn-nbctl --timeout=15 --format=csv --data=bare --no-heading --columns=external_ids find address_set",
+					Cmd:    "ovn-nbctl --timeout=15 --format=csv --data=bare --no-heading --columns=_uuid,name,external_ids find address_set",
 					Output: namespacesRes,
 				})
 
@@ -99,17 +112,119 @@ var _ = Describe("OVN Address Set operations", func() {
 				return nil
 			}
 
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+		It("reads the address sets from the NB database if a client is given", func() {
+			app.Action = func(ctx *cli.Context) error {
+				_, err := config.InitConfig(ctx, fexec, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				asFactory = NewOvnAddressSetFactory(&asListClient{
+					addressSets: []*goovn.AddressSet{
+						{
+							UUID:       fakeUUID,
+							Name:       hashedAddressSet("ns1.foo.bar_v4"),
+							ExternalID: map[interface{}]interface{}{"name": "ns1.foo.bar_v4"},
+						},
+						{
+							UUID:       fakeUUIDv6,
+							Name:       hashedAddressSet("ns1.foo.bar_v6"),
+							ExternalID: map[interface{}]interface{}{"name": "ns1.foo.bar_v6"},
+						},
+						{
+							UUID: "00000000-0000-0000-0000-000000000000",
+							Name: "unmanaged",
+						},
+					},
+				})
+				// ns1.foo.bar_v4 is known, so only the set's addresses are updated
+				fexec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUID,
+				})
+
+				var found []string
+				err = asFactory.ForEachAddressSet(func(addrSetName, namespaceName, nameSuffix string) {
+					Expect(namespaceName).To(Equal("ns1"))
+					Expect(nameSuffix).To(Equal("foo"))
+					found = append(found, addrSetName)
+				})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(found).To(Equal([]string{"ns1.foo.bar"}))
+
+				err = asFactory.PopulateCache()
+				Expect(err).NotTo(HaveOccurred())
+				_, err = asFactory.NewAddressSet("ns1.foo.bar", nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+				return nil
+			}
+
 			err := app.Run([]string{app.Name})
 			Expect(err).NotTo(HaveOccurred())
 		})
 	})
 
 	Context("when creating an address set object", func() {
+		It("uses the address sets found by PopulateCache instead of looking them up", func() {
+			app.Action = func(ctx *cli.Context) error {
+				const addr1 string = "1.2.3.4"
+
+				_, err := config.InitConfig(ctx, fexec, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 --format=csv --data=bare --no-heading --columns=_uuid,name,external_ids find address_set",
+					Output: fmt.Sprintf("%s,a16990491322166530807,name=foobar_v4\n", fakeUUID),
+				})
+				// foobar exists so it is just updated; baz doesn't so it is created
+				fexec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUID,
+					`ovn-nbctl --timeout=15 add address_set ` + fakeUUID + ` addresses "` + addr1 + `"`,
+				})
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 create address_set name=a35521202252523765 external-ids:name=baz_v4",
+					Output: fakeUUIDv6,
+				})
+				// baz is destroyed; after invalidating the cache foobar is looked up again
+				fexec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 --if-exists destroy address_set " + fakeUUIDv6,
+				})
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find address_set name=a16990491322166530807",
+					Output: fakeUUID,
+				})
+				fexec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUID,
+				})
+
+				err = asFactory.PopulateCache()
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = asFactory.NewAddressSet("foobar", []net.IP{net.ParseIP(addr1)})
+				Expect(err).NotTo(HaveOccurred())
+				as, err := asFactory.NewAddressSet("baz", nil)
+				Expect(err).NotTo(HaveOccurred())
+				err = as.Destroy()
+				Expect(err).NotTo(HaveOccurred())
+
+				asFactory.InvalidateCache()
+				_, err = asFactory.NewAddressSet("foobar", nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("re-uses an existing address set and replaces IPs", func() {
 			app.Action = func(ctx *cli.Context) error {
 				const (
 					addr1 string = "1.2.3.4"
 					addr2 string = "5.6.7.8"
+					addr3 string = "9.10.11.12"
 				)
 
 				_, err := config.InitConfig(ctx, fexec, nil)
@@ -119,8 +234,14 @@ var _ = Describe("OVN Address Set operations", func() {
 					Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find address_set name=a16990491322166530807",
 					Output: fakeUUID,
 				})
+				// only the IPs that differ are added and removed
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUID,
+					Output: addr2 + " " + addr3,
+				})
 				fexec.AddFakeCmdsNoOutputNoError([]string{
-					`ovn-nbctl --timeout=15 set address_set ` + fakeUUID + ` addresses="` + addr1 + `" "` + addr2 + `"`,
+					`ovn-nbctl --timeout=15 add address_set ` + fakeUUID + ` addresses "` + addr1 + `"` +
+						` -- remove address_set ` + fakeUUID + ` addresses "` + addr3 + `"`,
 				})
 
 				_, err = asFactory.NewAddressSet("foobar", []net.IP{net.ParseIP(addr1), net.ParseIP(addr2)})
@@ -142,8 +263,12 @@ var _ = Describe("OVN Address Set operations", func() {
 					Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find address_set name=a16990491322166530807",
 					Output: fakeUUID,
 				})
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUID,
+					Output: "1.2.3.4",
+				})
 				fexec.AddFakeCmdsNoOutputNoError([]string{
-					"ovn-nbctl --timeout=15 clear address_set " + fakeUUID + " addresses",
+					`ovn-nbctl --timeout=15 remove address_set ` + fakeUUID + ` addresses "1.2.3.4"`,
 				})
 
 				_, err = asFactory.NewAddressSet("foobar", nil)
@@ -195,7 +320,7 @@ var _ = Describe("OVN Address Set operations", func() {
 				Output: fakeUUID,
 			})
 			fexec.AddFakeCmdsNoOutputNoError([]string{
-				"ovn-nbctl --timeout=15 clear address_set " + fakeUUID + " addresses",
+				"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUID,
 				"ovn-nbctl --timeout=15 --if-exists destroy address_set " + fakeUUID,
 			})
 
@@ -281,6 +406,123 @@ var _ = Describe("OVN Address Set operations", func() {
 			err := app.Run([]string{app.Name})
 			Expect(err).NotTo(HaveOccurred())
 		})
+
+		It("reconciles a dual stack address set by adding and removing only changed IPs", func() {
+			app.Action = func(ctx *cli.Context) error {
+				const addr1 string = "1.2.3.4"
+				const addr2 string = "2001:db8::1"
+				const addr3 string = "5.6.7.8"
+				const addr4 string = "2001:db8::2"
+
+				_, err := config.InitConfig(ctx, fexec, nil)
+				Expect(err).NotTo(HaveOccurred())
+				config.IPv6Mode = true
+
+				fexec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find address_set name=a16990491322166530807",
+				})
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    `ovn-nbctl --timeout=15 create address_set name=a16990491322166530807 external-ids:name=foobar_v4 addresses="` + addr1 + `"`,
+					Output: fakeUUID,
+				})
+				fexec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find address_set name=a16990493521189787229",
+				})
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    `ovn-nbctl --timeout=15 create address_set name=a16990493521189787229 external-ids:name=foobar_v6 addresses="` + addr2 + `"`,
+					Output: fakeUUIDv6,
+				})
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUID,
+					Output: addr1,
+				})
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUIDv6,
+					Output: addr2,
+				})
+				fexec.AddFakeCmdsNoOutputNoError([]string{
+					`ovn-nbctl --timeout=15 add address_set ` + fakeUUID + ` addresses "` + addr3 + `"` +
+						` -- add address_set ` + fakeUUIDv6 + ` addresses "` + addr4 + `"` +
+						` -- remove address_set ` + fakeUUIDv6 + ` addresses "` + addr2 + `"`,
+				})
+
+				as, err := asFactory.NewAddressSet("foobar", []net.IP{net.ParseIP(addr1), net.ParseIP(addr2)})
+				Expect(err).NotTo(HaveOccurred())
+
+				err = as.ReconcileIPs([]net.IP{net.ParseIP(addr1), net.ParseIP(addr3), net.ParseIP(addr4)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(as.Size()).To(Equal(3))
+
+				Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rejects IPv6 addresses for an IPv4-only address set", func() {
+			app.Action = func(ctx *cli.Context) error {
+				_, err := config.InitConfig(ctx, fexec, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				fexec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find address_set name=a16990491322166530807",
+				})
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 create address_set name=a16990491322166530807 external-ids:name=foobar_v4",
+					Output: fakeUUID,
+				})
+
+				as, err := asFactory.NewAddressSet("foobar", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = as.AddIPs([]net.IP{net.ParseIP("1.2.3.4"), net.ParseIP("2001:db8::1")})
+				Expect(err).To(MatchError(ContainSubstring("IPv6 address 2001:db8::1 can't be used in an IPv4-only address set")))
+				err = as.DeleteIPs([]net.IP{net.ParseIP("2001:db8::1")})
+				Expect(err).To(HaveOccurred())
+				err = as.AddIPs([]net.IP{nil})
+				Expect(err).To(MatchError(ContainSubstring("invalid IP address")))
+
+				Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("rejects IPv4 addresses for an IPv6-only address set", func() {
+			app.Action = func(ctx *cli.Context) error {
+				_, err := config.InitConfig(ctx, fexec, nil)
+				Expect(err).NotTo(HaveOccurred())
+				config.IPv4Mode = false
+				config.IPv6Mode = true
+
+				v6HashName := hashedAddressSet("foobar_v6")
+				fexec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find address_set name=" + v6HashName,
+				})
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 create address_set name=" + v6HashName + " external-ids:name=foobar_v6",
+					Output: fakeUUIDv6,
+				})
+
+				as, err := asFactory.NewAddressSet("foobar", nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = as.AddIPs([]net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("1.2.3.4")})
+				Expect(err).To(MatchError(ContainSubstring("IPv4 address 1.2.3.4 can't be used in an IPv6-only address set")))
+				err = as.DeleteIPs([]net.IP{net.ParseIP("1.2.3.4")})
+				Expect(err).To(HaveOccurred())
+
+				Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
 	})
 
 	Context("Dual stack : when creating an address set object", func() {
@@ -302,7 +544,8 @@ var _ = Describe("OVN Address Set operations", func() {
 					Output: fakeUUID,
 				})
 				fexec.AddFakeCmdsNoOutputNoError([]string{
-					`ovn-nbctl --timeout=15 set address_set ` + fakeUUID + ` addresses="` + addr1 + `" "` + addr2 + `"`,
+					"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUID,
+					`ovn-nbctl --timeout=15 add address_set ` + fakeUUID + ` addresses "` + addr1 + `" "` + addr2 + `"`,
 				})
 
 				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
@@ -310,7 +553,8 @@ var _ = Describe("OVN Address Set operations", func() {
 					Output: fakeUUIDv6,
 				})
 				fexec.AddFakeCmdsNoOutputNoError([]string{
-					`ovn-nbctl --timeout=15 set address_set ` + fakeUUIDv6 + ` addresses="` + addr3 + `" "` + addr4 + `"`,
+					"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUIDv6,
+					`ovn-nbctl --timeout=15 add address_set ` + fakeUUIDv6 + ` addresses "` + addr3 + `" "` + addr4 + `"`,
 				})
 
 				_, err = asFactory.NewAddressSet("foobar", []net.IP{net.ParseIP(addr1), net.ParseIP(addr2),
@@ -335,7 +579,7 @@ var _ = Describe("OVN Address Set operations", func() {
 					Output: fakeUUID,
 				})
 				fexec.AddFakeCmdsNoOutputNoError([]string{
-					"ovn-nbctl --timeout=15 clear address_set " + fakeUUID + " addresses",
+					"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUID,
 				})
 
 				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
@@ -343,7 +587,7 @@ var _ = Describe("OVN Address Set operations", func() {
 					Output: fakeUUIDv6,
 				})
 				fexec.AddFakeCmdsNoOutputNoError([]string{
-					`ovn-nbctl --timeout=15 clear address_set ` + fakeUUIDv6 + " addresses",
+					"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUIDv6,
 				})
 
 				_, err = asFactory.NewAddressSet("foobar", nil)
@@ -408,7 +652,7 @@ var _ = Describe("OVN Address Set operations", func() {
 				Output: fakeUUID,
 			})
 			fexec.AddFakeCmdsNoOutputNoError([]string{
-				"ovn-nbctl --timeout=15 clear address_set " + fakeUUID + " addresses",
+				"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUID,
 			})
 
 			fexec.AddFakeCmd(&ovntest.ExpectedCmd{
@@ -416,7 +660,7 @@ var _ = Describe("OVN Address Set operations", func() {
 				Output: fakeUUIDv6,
 			})
 			fexec.AddFakeCmdsNoOutputNoError([]string{
-				"ovn-nbctl --timeout=15 clear address_set " + fakeUUIDv6 + " addresses",
+				"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=addresses list address_set " + fakeUUIDv6,
 			})
 			fexec.AddFakeCmdsNoOutputNoError([]string{
 				"ovn-nbctl --timeout=15 --if-exists destroy address_set " + fakeUUID,
@@ -461,11 +705,8 @@ var _ = Describe("OVN Address Set operations", func() {
 					Output: fakeUUIDv6,
 				})
 				fexec.AddFakeCmdsNoOutputNoError([]string{
-					`ovn-nbctl --timeout=15 add address_set ` + fakeUUID + ` addresses "` + addr1 + `"`,
-				})
-
-				fexec.AddFakeCmdsNoOutputNoError([]string{
-					`ovn-nbctl --timeout=15 add address_set ` + fakeUUIDv6 + ` addresses "` + addr2 + `"`,
+					`ovn-nbctl --timeout=15 add address_set ` + fakeUUID + ` addresses "` + addr1 + `"` +
+						` -- add address_set ` + fakeUUIDv6 + ` addresses "` + addr2 + `"`,
 				})
 
 				as, err := asFactory.NewAddressSet("foobar", nil)
@@ -511,10 +752,8 @@ var _ = Describe("OVN Address Set operations", func() {
 				})
 
 				fexec.AddFakeCmdsNoOutputNoError([]string{
-					`ovn-nbctl --timeout=15 remove address_set ` + fakeUUID + ` addresses "` + addr1 + `"`,
-				})
-				fexec.AddFakeCmdsNoOutputNoError([]string{
-					`ovn-nbctl --timeout=15 remove address_set ` + fakeUUIDv6 + ` addresses "` + addr2 + `"`,
+					`ovn-nbctl --timeout=15 remove address_set ` + fakeUUID + ` addresses "` + addr1 + `"` +
+						` -- remove address_set ` + fakeUUIDv6 + ` addresses "` + addr2 + `"`,
 				})
 
 				as, err := asFactory.NewAddressSet("foobar", []net.IP{net.ParseIP(addr1), net.ParseIP(addr2)})
@@ -536,3 +775,65 @@ var _ = Describe("OVN Address Set operations", func() {
 		})
 	})
 })
+
+func benchmarkAddressSetAddIPs(b *testing.B, batch bool) {
+	const numIPs = 500
+
+	config.PrepareTestConfig()
+	ips := make([]net.IP, 0, numIPs)
+	quoted := make([]string, 0, numIPs)
+	for i := 0; i < numIPs; i++ {
+		ip := net.IPv4(10, 128, byte(i/250), byte(i%250+1))
+		ips = append(ips, ip)
+		quoted = append(quoted, `"`+ip.String()+`"`)
+	}
+
+	for n := 0; n < b.N; n++ {
+		b.StopTimer()
+		fexec := ovntest.NewFakeExec()
+		if batch {
+			fexec.AddFakeCmdsNoOutputNoError([]string{
+				"ovn-nbctl --timeout=15 add address_set " + fakeUUID + " addresses " + strings.Join(quoted, " "),
+			})
+		} else {
+			for _, ip := range quoted {
+				fexec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 add address_set " + fakeUUID + " addresses " + ip,
+				})
+			}
+		}
+		if err := util.SetExec(fexec); err != nil {
+			b.Fatal(err)
+		}
+		as := &ovnAddressSets{
+			name: "foobar",
+			ipv4: &ovnAddressSet{
+				name:     getIPv4ASName("foobar"),
+				hashName: hashedAddressSet(getIPv4ASName("foobar")),
+				uuid:     fakeUUID,
+				ips:      make(map[string]net.IP),
+			},
+		}
+		b.StartTimer()
+
+		if batch {
+			if err := as.AddIPs(ips); err != nil {
+				b.Fatal(err)
+			}
+		} else {
+			for _, ip := range ips {
+				if err := as.AddIPs([]net.IP{ip}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkAddressSetAddIPsPerAddress(b *testing.B) {
+	benchmarkAddressSetAddIPs(b, false)
+}
+
+func BenchmarkAddressSetAddIPsBatch(b *testing.B) {
+	benchmarkAddressSetAddIPs(b, true)
+}
@@ -100,6 +100,9 @@ func (oc *Controller) addEgressFirewall(egressFirewall *egressfirewallapi.Egress
 	if err != nil {
 		return []error{fmt.Errorf("error unable to add egressfirewall %s, cannot list nodes: %s", egressFirewall.Name, err)}
 	}
+	if nsInfo.addressSet == nil {
+		return []error{fmt.Errorf("error unable to add egressfirewall %s, namespace %s has no address set", egressFirewall.Name, egressFirewall.Namespace)}
+	}
 	var joinSwitches []string
 	for _, node := range existingNodes.Items {
 		joinSwitches = append(joinSwitches, joinSwitch(node.Name))
@@ -113,9 +113,12 @@ func (ovn *Controller) AddEndpoints(ep *kapi.Endpoints) error {
 
 func (ovn *Controller) handleNodePortLB(node *kapi.Node) error {
 	gatewayRouter := gwRouterPrefix + node.Name
-	var physicalIPs []string
-	if physicalIPs, _ = ovn.getGatewayPhysicalIPs(gatewayRouter); physicalIPs == nil {
-		return fmt.Errorf("gateway physical IP for node %q does not yet exist", node.Name)
+	physicalIPs, err := ovn.getGatewayPhysicalIPs(gatewayRouter)
+	if err != nil {
+		if isGatewayRouterNotFoundError(err) {
+			return err
+		}
+		return fmt.Errorf("gateway physical IP for node %q does not yet exist: %v", node.Name, err)
 	}
 	namespaces, err := ovn.watchFactory.GetNamespaces()
 	if err != nil {
@@ -141,11 +144,24 @@ func (ovn *Controller) handleNodePortLB(node *kapi.Node) error {
 				if !isFound {
 					continue
 				}
+				if !ovn.SCTPSupport && svcPort.Protocol == kapi.ProtocolSCTP {
+					klog.Warningf("Skipping NodePort for unsupported SCTP protocol: %s, %s", ep.Namespace, ep.Name)
+					continue
+				}
 				k8sNSLb, _ := ovn.getGatewayLoadBalancer(gatewayRouter, svcPort.Protocol)
 				if k8sNSLb == "" {
 					return fmt.Errorf("%s load balancer for node %q does not yet exist", svcPort.Protocol, node.Name)
 				}
-				err = ovn.createLoadBalancerVIPs(k8sNSLb, physicalIPs, svcPort.NodePort, lbEps.IPs, lbEps.Port)
+				// Only create VIPs for the IP families the service has
+				// endpoints in, as createGatewayVIPs does
+				vipIPs, staleIPs := splitIPsByTargetFamily(physicalIPs, lbEps.IPs)
+				for _, staleIP := range staleIPs {
+					vip := util.JoinHostPortInt32(staleIP, svcPort.NodePort)
+					if err := ovn.deleteLoadBalancerVIP(k8sNSLb, vip); err != nil {
+						klog.Error(err)
+					}
+				}
+				err = ovn.createLoadBalancerVIPs(k8sNSLb, vipIPs, svcPort.NodePort, lbEps.IPs, lbEps.Port)
 				if err != nil {
 					klog.Errorf("Failed to create VIP in load balancer %s - %v", k8sNSLb, err)
 					continue
@@ -170,6 +186,10 @@ func (ovn *Controller) deleteEndpoints(ep *kapi.Endpoints) error {
 		return nil
 	}
 	for _, svcPort := range svc.Spec.Ports {
+		if !ovn.SCTPSupport && svcPort.Protocol == kapi.ProtocolSCTP {
+			// nothing was created for it
+			continue
+		}
 		var lb string
 		lb, err = ovn.getLoadBalancer(svcPort.Protocol)
 		if err != nil {
@@ -51,15 +51,17 @@ func (e endpoints) addNodePortPortCmds(fexec *ovntest.FakeExec, service v1.Servi
 		Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=name find logical_router options:chassis!=null",
 		Output: gatewayRouters,
 	})
+	for _, gatewayR := range strings.Fields(gatewayRouters) {
+		fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+			Cmd:    "ovn-nbctl --timeout=15 get logical_router " + gatewayR + " external_ids:physical_ips",
+			Output: "169.254.33.2",
+		})
+	}
 	for idx, gatewayR := range strings.Fields(gatewayRouters) {
 		fexec.AddFakeCmd(&ovntest.ExpectedCmd{
 			Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find load_balancer external_ids:TCP_lb_gateway_router=" + gatewayR,
 			Output: "load_balancer_" + strconv.Itoa(idx),
 		})
-		fexec.AddFakeCmd(&ovntest.ExpectedCmd{
-			Cmd:    "ovn-nbctl --timeout=15 get logical_router " + gatewayR + " external_ids:physical_ips",
-			Output: "169.254.33.2",
-		})
 		fexec.AddFakeCmdsNoOutputNoError([]string{
 			fmt.Sprintf("ovn-nbctl --timeout=15 set load_balancer load_balancer_%s vips:\"%s:%v\"=\"%s:%v\"", strconv.Itoa(idx), "169.254.33.2", service.Spec.Ports[0].NodePort, endpoint.Subsets[0].Addresses[0].IP, endpoint.Subsets[0].Ports[0].Port),
 		})
@@ -337,6 +339,168 @@ var _ = Describe("OVN Namespace Operations", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 
+		It("skips SCTP NodePorts on new nodes when OVN does not support SCTP", func() {
+			app.Action = func(ctx *cli.Context) error {
+
+				endpointsT := *newEndpoints("endpoint-service1", "namespace1",
+					[]v1.EndpointAddress{
+						{
+							IP: "10.125.0.2",
+						},
+					},
+					[]v1.EndpointPort{
+						{
+							Name:     "portSctp1",
+							Port:     8080,
+							Protocol: v1.ProtocolSCTP,
+						},
+					})
+
+				serviceT := *newService("endpoint-service1", "namespace1", "172.124.0.2",
+					[]v1.ServicePort{
+						{
+							Name:       "portSctp1",
+							NodePort:   31111,
+							Protocol:   v1.ProtocolSCTP,
+							TargetPort: intstr.FromInt(8080),
+						},
+					},
+					v1.ServiceTypeNodePort,
+					nil,
+				)
+
+				tExec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 get logical_router GR_node1 external_ids:physical_ips",
+					Output: "169.254.33.2",
+				})
+
+				fakeOvn.start(ctx,
+					&v1.NamespaceList{
+						Items: []v1.Namespace{
+							*newNamespace("namespace1"),
+						},
+					},
+					&v1.EndpointsList{
+						Items: []v1.Endpoints{
+							endpointsT,
+						},
+					},
+					&v1.ServiceList{
+						Items: []v1.Service{
+							serviceT,
+						},
+					},
+				)
+				fakeOvn.controller.SCTPSupport = false
+
+				node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+				err := fakeOvn.controller.handleNodePortLB(node)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tExec.CalledMatchesExpected()).To(BeTrue(), tExec.ErrorDesc)
+
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("only creates NodePort VIPs on new nodes for the IP families with endpoints", func() {
+			app.Action = func(ctx *cli.Context) error {
+
+				endpointsT := *newEndpoints("endpoint-service1", "namespace1",
+					[]v1.EndpointAddress{
+						{
+							IP: "10.125.0.2",
+						},
+					},
+					[]v1.EndpointPort{
+						{
+							Name:     "portTcp1",
+							Port:     8080,
+							Protocol: v1.ProtocolTCP,
+						},
+					})
+
+				serviceT := *newService("endpoint-service1", "namespace1", "172.124.0.2",
+					[]v1.ServicePort{
+						{
+							Name:       "portTcp1",
+							NodePort:   31111,
+							Protocol:   v1.ProtocolTCP,
+							TargetPort: intstr.FromInt(8080),
+						},
+					},
+					v1.ServiceTypeNodePort,
+					nil,
+				)
+
+				tExec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 get logical_router GR_node1 external_ids:physical_ips",
+					Output: "169.254.33.2,fd99::2",
+				})
+				tExec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find load_balancer external_ids:TCP_lb_gateway_router=GR_node1",
+					Output: "load_balancer_1",
+				})
+				tExec.AddFakeCmdsNoOutputNoError([]string{
+					`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "[fd99::2]:31111"`,
+					`ovn-nbctl --timeout=15 set load_balancer load_balancer_1 vips:"169.254.33.2:31111"="10.125.0.2:8080"`,
+				})
+
+				fakeOvn.start(ctx,
+					&v1.NamespaceList{
+						Items: []v1.Namespace{
+							*newNamespace("namespace1"),
+						},
+					},
+					&v1.EndpointsList{
+						Items: []v1.Endpoints{
+							endpointsT,
+						},
+					},
+					&v1.ServiceList{
+						Items: []v1.Service{
+							serviceT,
+						},
+					},
+				)
+
+				node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+				err := fakeOvn.controller.handleNodePortLB(node)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tExec.CalledMatchesExpected()).To(BeTrue(), tExec.ErrorDesc)
+
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns a gateway router not found error for nodes whose gateway router does not exist yet", func() {
+			app.Action = func(ctx *cli.Context) error {
+				tExec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 get logical_router GR_node1 external_ids:physical_ips",
+					Stderr: `ovn-nbctl: no row "GR_node1" in table Logical_Router`,
+					Err:    fmt.Errorf("exit status 1"),
+				})
+
+				fakeOvn.start(ctx)
+
+				node := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}}
+				err := fakeOvn.controller.handleNodePortLB(node)
+				Expect(err).To(HaveOccurred())
+				Expect(isGatewayRouterNotFoundError(err)).To(BeTrue())
+				Expect(tExec.CalledMatchesExpected()).To(BeTrue(), tExec.ErrorDesc)
+
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("reconciles deleted endpoints", func() {
 			app.Action = func(ctx *cli.Context) error {
 
@@ -66,6 +66,13 @@ func (f *fakeAddressSetFactory) ForEachAddressSet(iteratorFn AddressSetIterFunc)
 	return nil
 }
 
+func (f *fakeAddressSetFactory) PopulateCache() error {
+	return nil
+}
+
+func (f *fakeAddressSetFactory) InvalidateCache() {
+}
+
 func (f *fakeAddressSetFactory) DestroyAddressSetInBackingStore(name string) error {
 	if _, ok := f.sets[name]; ok {
 		f.removeAddressSet(name)
@@ -211,6 +218,20 @@ func (as *fakeAddressSets) GetName() string {
 	return as.name
 }
 
+func (as *fakeAddressSets) Size() int {
+	as.Lock()
+	defer as.Unlock()
+
+	size := 0
+	if as.ipv4 != nil {
+		size += len(as.ipv4.ips)
+	}
+	if as.ipv6 != nil {
+		size += len(as.ipv6.ips)
+	}
+	return size
+}
+
 func (as *fakeAddressSets) AddIPs(ips []net.IP) error {
 	var err error
 	as.Lock()
@@ -247,6 +268,30 @@ func (as *fakeAddressSets) DeleteIPs(ips []net.IP) error {
 	return nil
 }
 
+func (as *fakeAddressSets) ReconcileIPs(ips []net.IP) error {
+	as.Lock()
+	defer as.Unlock()
+
+	if as.ipv4 != nil {
+		as.ipv4.clearIPs()
+	}
+	if as.ipv6 != nil {
+		as.ipv6.clearIPs()
+	}
+	for _, ip := range ips {
+		var err error
+		if utilnet.IsIPv6(ip) {
+			err = as.ipv6.addIP(ip)
+		} else {
+			err = as.ipv4.addIP(ip)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (as *fakeAddressSets) Destroy() error {
 	as.Lock()
 	defer as.Unlock()
@@ -282,6 +327,11 @@ func (as *fakeAddressSet) addIP(ip net.IP) error {
 	return nil
 }
 
+func (as *fakeAddressSet) clearIPs() {
+	Expect(as.destroyed).To(BeFalse())
+	as.ips = make(map[string]net.IP)
+}
+
 func (as *fakeAddressSet) deleteIP(ip net.IP) error {
 	as.Lock()
 	defer as.Unlock()
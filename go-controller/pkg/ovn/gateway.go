@@ -3,11 +3,15 @@ package ovn
 import (
 	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/util"
 
 	kapi "k8s.io/api/core/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog"
+	utilnet "k8s.io/utils/net"
 )
 
 const (
@@ -29,25 +33,73 @@ const (
 	nodeLocalSwitch          = "node_local_switch"
 	nodeSubnetPolicyPriority = "1004"
 	mgmtPortPolicyPriority   = "1005"
+
+	// gatewayCacheTTL is how long the list of gateway routers returned by
+	// getCachedGateways is reused before it is looked up again
+	gatewayCacheTTL = 5 * time.Second
 )
 
+// gatewayInfo is a gateway router and its physical IPs
+type gatewayInfo struct {
+	name        string
+	physicalIPs []string
+}
+
+// gatewayCache is a short-lived cache of the gateway routers, so that the
+// NodePort hot path doesn't have to look them up for every service port.
+// It must be invalidated whenever a gateway router is created or removed.
+type gatewayCache struct {
+	sync.Mutex
+	gateways []gatewayInfo
+	expiry   time.Time
+}
+
+// invalidate drops the cached gateway routers
+func (gc *gatewayCache) invalidate() {
+	gc.Lock()
+	defer gc.Unlock()
+	gc.gateways = nil
+	gc.expiry = time.Time{}
+}
+
 func (ovn *Controller) getOvnGateways() ([]string, string, error) {
 	// Return all created gateways.
-	out, stderr, err := util.RunOVNNbctl("--data=bare", "--no-heading",
+	out, stderr, err := util.RunOVNNbctlWithRetry("--data=bare", "--no-heading",
 		"--columns=name", "find",
 		"logical_router",
 		"options:chassis!=null")
 	return strings.Fields(out), stderr, err
 }
 
+type gatewayRouterNotFoundError struct {
+	gatewayRouter string
+}
+
+func (e gatewayRouterNotFoundError) Error() string {
+	return fmt.Sprintf("gateway router %s does not yet exist", e.gatewayRouter)
+}
+
+// isGatewayRouterNotFoundError returns true if the error indicates that a
+// gateway router has not been created yet, in which case the operation should
+// be retried later
+func isGatewayRouterNotFoundError(err error) bool {
+	_, ok := err.(gatewayRouterNotFoundError)
+	return ok
+}
+
+// getGatewayPhysicalIPs returns the physical IPs of gatewayRouter, or a
+// gatewayRouterNotFoundError if gatewayRouter doesn't exist
 func (ovn *Controller) getGatewayPhysicalIPs(gatewayRouter string) ([]string, error) {
-	physicalIPs, _, err := util.RunOVNNbctl("get", "logical_router",
+	physicalIPs, stderr, err := util.RunOVNNbctlWithRetry("get", "logical_router",
 		gatewayRouter, "external_ids:physical_ips")
 	if err == nil {
 		return strings.Split(physicalIPs, ","), nil
 	}
+	if strings.Contains(stderr, "no row") {
+		return nil, gatewayRouterNotFoundError{gatewayRouter: gatewayRouter}
+	}
 
-	physicalIP, _, err := util.RunOVNNbctl("get", "logical_router",
+	physicalIP, _, err := util.RunOVNNbctlWithRetry("get", "logical_router",
 		gatewayRouter, "external_ids:physical_ip")
 	if err != nil {
 		return nil, err
@@ -56,9 +108,37 @@ func (ovn *Controller) getGatewayPhysicalIPs(gatewayRouter string) ([]string, er
 	return []string{physicalIP}, nil
 }
 
+// getCachedGateways returns all created gateways along with their physical
+// IPs, looking them up only if the cached list is missing or expired.
+// Gateways that don't have a physical IP yet are left out.
+func (ovn *Controller) getCachedGateways() ([]gatewayInfo, error) {
+	ovn.gatewayCache.Lock()
+	defer ovn.gatewayCache.Unlock()
+	if ovn.gatewayCache.gateways != nil && time.Now().Before(ovn.gatewayCache.expiry) {
+		return ovn.gatewayCache.gateways, nil
+	}
+
+	gatewayRouters, _, err := ovn.getOvnGateways()
+	if err != nil {
+		return nil, err
+	}
+	gateways := make([]gatewayInfo, 0, len(gatewayRouters))
+	for _, gatewayRouter := range gatewayRouters {
+		physicalIPs, err := ovn.getGatewayPhysicalIPs(gatewayRouter)
+		if err != nil {
+			klog.Errorf("Gateway router %s does not have physical ip (%v)", gatewayRouter, err)
+			continue
+		}
+		gateways = append(gateways, gatewayInfo{name: gatewayRouter, physicalIPs: physicalIPs})
+	}
+	ovn.gatewayCache.gateways = gateways
+	ovn.gatewayCache.expiry = time.Now().Add(gatewayCacheTTL)
+	return gateways, nil
+}
+
 func (ovn *Controller) getGatewayLoadBalancer(gatewayRouter string, protocol kapi.Protocol) (string, error) {
 	externalIDKey := string(protocol) + "_lb_gateway_router"
-	loadBalancer, _, err := util.RunOVNNbctl("--data=bare", "--no-heading",
+	loadBalancer, _, err := util.RunOVNNbctlWithRetry("--data=bare", "--no-heading",
 		"--columns=_uuid", "find", "load_balancer",
 		"external_ids:"+externalIDKey+"="+
 			gatewayRouter)
@@ -71,31 +151,36 @@ func (ovn *Controller) getGatewayLoadBalancer(gatewayRouter string, protocol kap
 	return loadBalancer, nil
 }
 
+// createGatewayVIPs creates or updates the physical_ip:sourcePort VIPs on the
+// load balancer for protocol on every gateway router
 func (ovn *Controller) createGatewayVIPs(protocol kapi.Protocol, sourcePort int32, targetIPs []string, targetPort int32) error {
 	klog.V(5).Infof("Creating Gateway VIPs - %s, %d, [%v], %d", protocol, sourcePort, targetIPs, targetPort)
 
 	// Each gateway has a separate load-balancer for N/S traffic
 
-	gatewayRouters, _, err := ovn.getOvnGateways()
+	gateways, err := ovn.getCachedGateways()
 	if err != nil {
 		return err
 	}
 
-	for _, gatewayRouter := range gatewayRouters {
-		loadBalancer, err := ovn.getGatewayLoadBalancer(gatewayRouter, protocol)
+	for _, gateway := range gateways {
+		loadBalancer, err := ovn.getGatewayLoadBalancer(gateway.name, protocol)
 		if err != nil {
 			klog.Errorf("Gateway router %s does not have load balancer (%v)",
-				gatewayRouter, err)
-			continue
-		}
-		physicalIPs, err := ovn.getGatewayPhysicalIPs(gatewayRouter)
-		if err != nil {
-			klog.Errorf("Gateway router %s does not have physical ip (%v)", gatewayRouter, err)
+				gateway.name, err)
 			continue
 		}
 		// With the physical_ip:sourcePort as the VIP, add an entry in
-		// 'load_balancer'.
-		err = ovn.createLoadBalancerVIPs(loadBalancer, physicalIPs, sourcePort, targetIPs, targetPort)
+		// 'load_balancer' for each IP family the service has endpoints in,
+		// and remove the entries of the families it doesn't.
+		vipIPs, staleIPs := splitIPsByTargetFamily(gateway.physicalIPs, targetIPs)
+		for _, staleIP := range staleIPs {
+			vip := util.JoinHostPortInt32(staleIP, sourcePort)
+			if err := ovn.deleteLoadBalancerVIP(loadBalancer, vip); err != nil {
+				klog.Error(err)
+			}
+		}
+		err = ovn.createLoadBalancerVIPs(loadBalancer, vipIPs, sourcePort, targetIPs, targetPort)
 		if err != nil {
 			klog.Errorf("Failed to create VIP in load balancer %s - %v", loadBalancer, err)
 			continue
@@ -104,6 +189,44 @@ func (ovn *Controller) createGatewayVIPs(protocol kapi.Protocol, sourcePort int3
 	return nil
 }
 
+// splitIPsByTargetFamily splits ips into those that are of the same IP family
+// as at least one of targetIPs and those that are not. If targetIPs is empty,
+// all of ips are returned as matching, so that a service without endpoints
+// gets (rejecting) VIPs in every family.
+func splitIPsByTargetFamily(ips, targetIPs []string) ([]string, []string) {
+	if len(targetIPs) == 0 {
+		return ips, nil
+	}
+	var haveV4, haveV6 bool
+	for _, targetIP := range targetIPs {
+		if utilnet.IsIPv6String(targetIP) {
+			haveV6 = true
+		} else {
+			haveV4 = true
+		}
+	}
+	var matching, other []string
+	for _, ip := range ips {
+		if utilnet.IsIPv6String(ip) {
+			if haveV6 {
+				matching = append(matching, ip)
+			} else {
+				other = append(other, ip)
+			}
+		} else {
+			if haveV4 {
+				matching = append(matching, ip)
+			} else {
+				other = append(other, ip)
+			}
+		}
+	}
+	return matching, other
+}
+
+// deleteGatewayVIPs removes the physical_ip:sourcePort VIPs from the load
+// balancer for protocol on every gateway router. VIPs that don't exist are
+// ignored, so it is safe to call for a service that never had any.
 func (ovn *Controller) deleteGatewayVIPs(protocol kapi.Protocol, sourcePort int32) {
 	klog.V(5).Infof("Searching to remove Gateway VIPs - %s, %d", protocol, sourcePort)
 	gatewayRouters, _, err := ovn.getOvnGateways()
@@ -113,19 +236,54 @@ func (ovn *Controller) deleteGatewayVIPs(protocol kapi.Protocol, sourcePort int3
 	}
 
 	for _, gatewayRouter := range gatewayRouters {
-		loadBalancer, err := ovn.getGatewayLoadBalancer(gatewayRouter, protocol)
-		if err != nil {
-			klog.Errorf("Gateway router %s does not have load balancer (%v)", gatewayRouter, err)
-			continue
+		nodeName := strings.TrimPrefix(gatewayRouter, gwRouterPrefix)
+		if err := ovn.deleteNodeVIP(nodeName, protocol, sourcePort); err != nil {
+			klog.Error(err)
 		}
-		physicalIPs, err := ovn.getGatewayPhysicalIPs(gatewayRouter)
+	}
+}
+
+// deleteNodeVIP removes the physical_ip:sourcePort VIPs from the load balancer
+// for protocol on the given node's gateway router
+func (ovn *Controller) deleteNodeVIP(nodeName string, protocol kapi.Protocol, sourcePort int32) error {
+	gatewayRouter := gwRouterPrefix + nodeName
+	loadBalancer, err := ovn.getGatewayLoadBalancer(gatewayRouter, protocol)
+	if err != nil {
+		return fmt.Errorf("gateway router %s does not have load balancer (%v)", gatewayRouter, err)
+	}
+	physicalIPs, err := ovn.getGatewayPhysicalIPs(gatewayRouter)
+	if err != nil {
+		return fmt.Errorf("gateway router %s does not have physical ip (%v)", gatewayRouter, err)
+	}
+	var errs []error
+	for _, physicalIP := range physicalIPs {
+		// With the physical_ip:sourcePort as the VIP, delete an entry in 'load_balancer'.
+		vip := util.JoinHostPortInt32(physicalIP, sourcePort)
+		klog.V(5).Infof("Removing gateway VIP: %s from load balancer: %s", vip, loadBalancer)
+		if err := ovn.deleteLoadBalancerVIP(loadBalancer, vip); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
+
+// deleteNodeVIPs removes every VIP that was created on the load balancers of
+// the given node's gateway router, along with their reject ACLs, so that
+// nothing refers to them once the node's gateway is cleaned up
+func (ovn *Controller) deleteNodeVIPs(nodeName string) {
+	if !ovn.haveServiceLBs() {
+		// no VIPs have been created anywhere, so don't bother looking up
+		// the node's load balancers
+		return
+	}
+	gatewayRouter := gwRouterPrefix + nodeName
+	for _, protocol := range ovn.getLoadBalancerProtocols() {
+		loadBalancer, err := ovn.getGatewayLoadBalancer(gatewayRouter, protocol)
 		if err != nil {
-			klog.Errorf("Gateway router %s does not have physical ip (%v)", gatewayRouter, err)
+			klog.V(5).Infof("Gateway router %s does not have %s load balancer (%v)", gatewayRouter, protocol, err)
 			continue
 		}
-		for _, physicalIP := range physicalIPs {
-			// With the physical_ip:sourcePort as the VIP, delete an entry in 'load_balancer'.
-			vip := util.JoinHostPortInt32(physicalIP, sourcePort)
+		for _, vip := range ovn.getServiceLBVIPs(loadBalancer) {
 			klog.V(5).Infof("Removing gateway VIP: %s from load balancer: %s", vip, loadBalancer)
 			if err := ovn.deleteLoadBalancerVIP(loadBalancer, vip); err != nil {
 				klog.Error(err)
@@ -161,7 +319,7 @@ func (ovn *Controller) deleteExternalVIPs(service *kapi.Service, svcPort kapi.Se
 
 // getGatewayLoadBalancers find TCP, SCTP, UDP load-balancers from gateway router.
 func getGatewayLoadBalancers(gatewayRouter string) (string, string, string, error) {
-	lbTCP, stderr, err := util.RunOVNNbctl("--data=bare", "--no-heading",
+	lbTCP, stderr, err := util.RunOVNNbctlWithRetry("--data=bare", "--no-heading",
 		"--columns=_uuid", "find", "load_balancer",
 		"external_ids:TCP_lb_gateway_router="+gatewayRouter)
 	if err != nil {
@@ -169,7 +327,7 @@ func getGatewayLoadBalancers(gatewayRouter string) (string, string, string, erro
 			"load balancer, stderr: %q, error: %v", gatewayRouter, stderr, err)
 	}
 
-	lbUDP, stderr, err := util.RunOVNNbctl("--data=bare", "--no-heading",
+	lbUDP, stderr, err := util.RunOVNNbctlWithRetry("--data=bare", "--no-heading",
 		"--columns=_uuid", "find", "load_balancer",
 		"external_ids:UDP_lb_gateway_router="+gatewayRouter)
 	if err != nil {
@@ -177,7 +335,7 @@ func getGatewayLoadBalancers(gatewayRouter string) (string, string, string, erro
 			"load balancer, stderr: %q, error: %v", gatewayRouter, stderr, err)
 	}
 
-	lbSCTP, stderr, err := util.RunOVNNbctl("--data=bare", "--no-heading",
+	lbSCTP, stderr, err := util.RunOVNNbctlWithRetry("--data=bare", "--no-heading",
 		"--columns=_uuid", "find", "load_balancer",
 		"external_ids:SCTP_lb_gateway_router="+gatewayRouter)
 	if err != nil {
@@ -3,12 +3,16 @@ package ovn
 import (
 	"net"
 
+	"github.com/urfave/cli/v2"
+
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
 	ovntest "github.com/ovn-org/ovn-kubernetes/go-controller/pkg/testing"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/util"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+
+	kapi "k8s.io/api/core/v1"
 )
 
 var _ = Describe("Gateway Init Operations", func() {
@@ -357,3 +361,276 @@ node4 chassis=912d592c-904c-40cd-9ef1-c2e5b49a33dd lb_force_snat_ip=100.64.0.4`,
 		Expect(fexec.CalledMatchesExpected()).To(BeTrue())
 	})
 })
+
+var _ = Describe("Gateway VIP Operations", func() {
+	var (
+		app     *cli.App
+		fExec   *ovntest.FakeExec
+		fakeOvn *FakeOVN
+	)
+
+	BeforeEach(func() {
+		// Restore global default values before each testcase
+		config.PrepareTestConfig()
+
+		app = cli.NewApp()
+		app.Name = "test"
+		app.Flags = config.Flags
+
+		fExec = ovntest.NewFakeExec()
+		fakeOvn = NewFakeOVN(fExec)
+	})
+
+	AfterEach(func() {
+		fakeOvn.shutdown()
+	})
+
+	addGatewayLookupCmds := func() {
+		fExec.AddFakeCmd(&ovntest.ExpectedCmd{
+			Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=name find logical_router options:chassis!=null",
+			Output: "GR_1",
+		})
+		fExec.AddFakeCmd(&ovntest.ExpectedCmd{
+			Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find load_balancer external_ids:TCP_lb_gateway_router=GR_1",
+			Output: "load_balancer_1",
+		})
+		fExec.AddFakeCmd(&ovntest.ExpectedCmd{
+			Cmd:    "ovn-nbctl --timeout=15 get logical_router GR_1 external_ids:physical_ips",
+			Output: "169.254.33.2,fd99::2",
+		})
+	}
+
+	// createGatewayVIPs looks up the physical IPs of all gateways before
+	// their load balancers, and only when its gateway cache is cold
+	addCachedGatewayLookupCmds := func() {
+		fExec.AddFakeCmd(&ovntest.ExpectedCmd{
+			Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=name find logical_router options:chassis!=null",
+			Output: "GR_1",
+		})
+		fExec.AddFakeCmd(&ovntest.ExpectedCmd{
+			Cmd:    "ovn-nbctl --timeout=15 get logical_router GR_1 external_ids:physical_ips",
+			Output: "169.254.33.2,fd99::2",
+		})
+	}
+
+	addLoadBalancerLookupCmd := func() {
+		fExec.AddFakeCmd(&ovntest.ExpectedCmd{
+			Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find load_balancer external_ids:TCP_lb_gateway_router=GR_1",
+			Output: "load_balancer_1",
+		})
+	}
+
+	It("creates dual-stack gateway VIPs only for the families with endpoints", func() {
+		app.Action = func(ctx *cli.Context) error {
+			fakeOvn.start(ctx)
+
+			addCachedGatewayLookupCmds()
+			addLoadBalancerLookupCmd()
+			fExec.AddFakeCmdsNoOutputNoError([]string{
+				`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "[fd99::2]:30000"`,
+				`ovn-nbctl --timeout=15 set load_balancer load_balancer_1 vips:"169.254.33.2:30000"="10.128.1.3:8080"`,
+			})
+			addLoadBalancerLookupCmd()
+			fExec.AddFakeCmdsNoOutputNoError([]string{
+				`ovn-nbctl --timeout=15 set load_balancer load_balancer_1 vips:"169.254.33.2:30000"="10.128.1.3:8080"`,
+				`ovn-nbctl --timeout=15 set load_balancer load_balancer_1 vips:"[fd99::2]:30000"="[fd00:10:128:1::3]:8080"`,
+			})
+
+			err := fakeOvn.controller.createGatewayVIPs(kapi.ProtocolTCP, 30000, []string{"10.128.1.3"}, 8080)
+			Expect(err).NotTo(HaveOccurred())
+			err = fakeOvn.controller.createGatewayVIPs(kapi.ProtocolTCP, 30000, []string{"10.128.1.3", "fd00:10:128:1::3"}, 8080)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fExec.CalledMatchesExpected()).To(BeTrue(), fExec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{app.Name})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("creates bracketed VIPs for an IPv6 gateway with normalized IPv6 endpoints", func() {
+		app.Action = func(ctx *cli.Context) error {
+			fakeOvn.start(ctx)
+
+			fExec.AddFakeCmd(&ovntest.ExpectedCmd{
+				Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=name find logical_router options:chassis!=null",
+				Output: "GR_1",
+			})
+			fExec.AddFakeCmd(&ovntest.ExpectedCmd{
+				Cmd:    "ovn-nbctl --timeout=15 get logical_router GR_1 external_ids:physical_ips",
+				Output: "fd99::2",
+			})
+			addLoadBalancerLookupCmd()
+			fExec.AddFakeCmdsNoOutputNoError([]string{
+				`ovn-nbctl --timeout=15 set load_balancer load_balancer_1 vips:"[fd99::2]:30000"="[fd00:10:128:1::3]:8080,[fd00:10:128:1::4]:8080"`,
+			})
+
+			err := fakeOvn.controller.createGatewayVIPs(kapi.ProtocolTCP, 30000, []string{"fd00:10:128:1:0:0:0:3", "FD00:10:128:1::4"}, 8080)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fExec.CalledMatchesExpected()).To(BeTrue(), fExec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{app.Name})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("refuses to create VIPs for invalid IPs", func() {
+		app.Action = func(ctx *cli.Context) error {
+			fakeOvn.start(ctx)
+
+			err := fakeOvn.controller.createLoadBalancerVIPs("load_balancer_1", []string{"fd99::2"}, 30000, []string{"not-an-ip"}, 8080)
+			Expect(err).To(HaveOccurred())
+			err = fakeOvn.controller.createLoadBalancerVIPs("load_balancer_1", []string{"physical-ip"}, 30000, []string{"10.128.1.3"}, 8080)
+			Expect(err).To(HaveOccurred())
+			Expect(fExec.CalledMatchesExpected()).To(BeTrue(), fExec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{app.Name})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("creates gateway VIPs in every family for a service without endpoints", func() {
+		app.Action = func(ctx *cli.Context) error {
+			fakeOvn.start(ctx)
+
+			addCachedGatewayLookupCmds()
+			addLoadBalancerLookupCmd()
+			fExec.AddFakeCmdsNoOutputNoError([]string{
+				`ovn-nbctl --timeout=15 set load_balancer load_balancer_1 vips:"169.254.33.2:30000"=""`,
+				`ovn-nbctl --timeout=15 set load_balancer load_balancer_1 vips:"[fd99::2]:30000"=""`,
+			})
+
+			err := fakeOvn.controller.createGatewayVIPs(kapi.ProtocolTCP, 30000, nil, 8080)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fExec.CalledMatchesExpected()).To(BeTrue(), fExec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{app.Name})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("looks up the gateways again after the gateway cache is invalidated", func() {
+		app.Action = func(ctx *cli.Context) error {
+			fakeOvn.start(ctx)
+
+			for i := 0; i < 2; i++ {
+				addCachedGatewayLookupCmds()
+				addLoadBalancerLookupCmd()
+				fExec.AddFakeCmdsNoOutputNoError([]string{
+					`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "[fd99::2]:30000"`,
+					`ovn-nbctl --timeout=15 set load_balancer load_balancer_1 vips:"169.254.33.2:30000"="10.128.1.3:8080"`,
+				})
+				if i == 0 {
+					// served from the cache
+					addLoadBalancerLookupCmd()
+					fExec.AddFakeCmdsNoOutputNoError([]string{
+						`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "[fd99::2]:30001"`,
+						`ovn-nbctl --timeout=15 set load_balancer load_balancer_1 vips:"169.254.33.2:30001"="10.128.1.3:8080"`,
+					})
+				}
+			}
+
+			err := fakeOvn.controller.createGatewayVIPs(kapi.ProtocolTCP, 30000, []string{"10.128.1.3"}, 8080)
+			Expect(err).NotTo(HaveOccurred())
+			err = fakeOvn.controller.createGatewayVIPs(kapi.ProtocolTCP, 30001, []string{"10.128.1.3"}, 8080)
+			Expect(err).NotTo(HaveOccurred())
+			fakeOvn.controller.gatewayCache.invalidate()
+			err = fakeOvn.controller.createGatewayVIPs(kapi.ProtocolTCP, 30000, []string{"10.128.1.3"}, 8080)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fExec.CalledMatchesExpected()).To(BeTrue(), fExec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{app.Name})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("deletes gateway VIPs in every family, even if they are already gone", func() {
+		app.Action = func(ctx *cli.Context) error {
+			fakeOvn.start(ctx)
+
+			for i := 0; i < 2; i++ {
+				addGatewayLookupCmds()
+				fExec.AddFakeCmdsNoOutputNoError([]string{
+					`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "169.254.33.2:30000"`,
+					`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "[fd99::2]:30000"`,
+				})
+			}
+
+			fakeOvn.controller.deleteGatewayVIPs(kapi.ProtocolTCP, 30000)
+			fakeOvn.controller.deleteGatewayVIPs(kapi.ProtocolTCP, 30000)
+			Expect(fExec.CalledMatchesExpected()).To(BeTrue(), fExec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{app.Name})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("deletes a node's gateway VIPs for a single port", func() {
+		app.Action = func(ctx *cli.Context) error {
+			fakeOvn.start(ctx)
+
+			addLoadBalancerLookupCmd()
+			fExec.AddFakeCmd(&ovntest.ExpectedCmd{
+				Cmd:    "ovn-nbctl --timeout=15 get logical_router GR_1 external_ids:physical_ips",
+				Output: "169.254.33.2,fd99::2",
+			})
+			fExec.AddFakeCmdsNoOutputNoError([]string{
+				`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "169.254.33.2:30000"`,
+				`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "[fd99::2]:30000"`,
+			})
+
+			err := fakeOvn.controller.deleteNodeVIP("1", kapi.ProtocolTCP, 30000)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fExec.CalledMatchesExpected()).To(BeTrue(), fExec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{app.Name})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("deletes all of a node's gateway VIPs when the node goes away", func() {
+		app.Action = func(ctx *cli.Context) error {
+			fakeOvn.start(ctx)
+
+			addCachedGatewayLookupCmds()
+			addLoadBalancerLookupCmd()
+			fExec.AddFakeCmdsNoOutputNoError([]string{
+				`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "[fd99::2]:30000"`,
+				`ovn-nbctl --timeout=15 set load_balancer load_balancer_1 vips:"169.254.33.2:30000"="10.128.1.3:8080"`,
+			})
+			addLoadBalancerLookupCmd()
+			fExec.AddFakeCmdsNoOutputNoError([]string{
+				`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "[fd99::2]:30001"`,
+				`ovn-nbctl --timeout=15 set load_balancer load_balancer_1 vips:"169.254.33.2:30001"="10.128.1.3:8080"`,
+			})
+
+			err := fakeOvn.controller.createGatewayVIPs(kapi.ProtocolTCP, 30000, []string{"10.128.1.3"}, 8080)
+			Expect(err).NotTo(HaveOccurred())
+			err = fakeOvn.controller.createGatewayVIPs(kapi.ProtocolTCP, 30001, []string{"10.128.1.3"}, 8080)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fakeOvn.controller.getServiceLBVIPs("load_balancer_1")).To(Equal([]string{"169.254.33.2:30000", "169.254.33.2:30001"}))
+
+			addLoadBalancerLookupCmd()
+			fExec.AddFakeCmdsNoOutputNoError([]string{
+				`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "169.254.33.2:30000"`,
+				`ovn-nbctl --timeout=15 --if-exists remove load_balancer load_balancer_1 vips "169.254.33.2:30001"`,
+				"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find load_balancer external_ids:UDP_lb_gateway_router=GR_1",
+			})
+
+			fakeOvn.controller.deleteNodeVIPs("1")
+			Expect(fakeOvn.controller.getServiceLBVIPs("load_balancer_1")).To(BeEmpty())
+			Expect(fakeOvn.controller.serviceLBMap).NotTo(HaveKey("load_balancer_1"))
+			Expect(fExec.CalledMatchesExpected()).To(BeTrue(), fExec.ErrorDesc)
+			return nil
+		}
+
+		err := app.Run([]string{app.Name})
+		Expect(err).NotTo(HaveOccurred())
+	})
+})
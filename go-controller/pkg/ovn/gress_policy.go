@@ -2,6 +2,7 @@ package ovn
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
@@ -78,13 +79,49 @@ func newGressPolicy(policyType knet.PolicyType, idx int, namespace, name string)
 	}
 }
 
+// getPolicyAddressSetName returns the name of the address set holding the
+// peers of the idx'th ingress or egress section of a network policy
+func getPolicyAddressSetName(namespace, policyName string, policyType knet.PolicyType, idx int) string {
+	direction := strings.ToLower(string(policyType))
+	return fmt.Sprintf("%s.%s.%s.%d", namespace, policyName, direction, idx)
+}
+
+// parsePolicyAddressSetName is the strict inverse of getPolicyAddressSetName.
+// Unlike the name parsing done by ForEachAddressSet, which assumes the policy
+// name is the second dot-separated part, it allows for policy names that
+// contain dots (namespace names cannot), and it returns an error for any name
+// that it cannot parse unambiguously rather than guessing.
+func parsePolicyAddressSetName(name string) (namespace, policyName string, policyType knet.PolicyType, idx int, err error) {
+	parts := strings.Split(name, ".")
+	if len(parts) < 4 {
+		return "", "", "", 0, fmt.Errorf("address set name %q is not a network policy address set name", name)
+	}
+	namespace = parts[0]
+	policyName = strings.Join(parts[1:len(parts)-2], ".")
+	if namespace == "" || policyName == "" {
+		return "", "", "", 0, fmt.Errorf("address set name %q has an empty namespace or policy name", name)
+	}
+	switch parts[len(parts)-2] {
+	case "ingress":
+		policyType = knet.PolicyTypeIngress
+	case "egress":
+		policyType = knet.PolicyTypeEgress
+	default:
+		return "", "", "", 0, fmt.Errorf("address set name %q has invalid direction %q", name, parts[len(parts)-2])
+	}
+	idx, err = strconv.Atoi(parts[len(parts)-1])
+	if err != nil || idx < 0 {
+		return "", "", "", 0, fmt.Errorf("address set name %q has invalid index %q", name, parts[len(parts)-1])
+	}
+	return namespace, policyName, policyType, idx, nil
+}
+
 func (gp *gressPolicy) ensurePeerAddressSet(factory AddressSetFactory) error {
 	if gp.peerAddressSet != nil {
 		return nil
 	}
 
-	direction := strings.ToLower(string(gp.policyType))
-	asName := fmt.Sprintf("%s.%s.%s.%d", gp.policyNamespace, gp.policyName, direction, gp.idx)
+	asName := getPolicyAddressSetName(gp.policyNamespace, gp.policyName, gp.policyType, gp.idx)
 	as, err := factory.NewAddressSet(asName, nil)
 	if err != nil {
 		return err
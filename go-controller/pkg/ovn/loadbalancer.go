@@ -102,22 +102,45 @@ func (ovn *Controller) configureLoadBalancer(lb, sourceIP string, sourcePort int
 	return nil
 }
 
+// getLoadBalancerProtocols returns the protocols that there are load balancers
+// for: TCP, UDP, and SCTP if this version of OVN supports it
+func (ovn *Controller) getLoadBalancerProtocols() []kapi.Protocol {
+	protocols := []kapi.Protocol{kapi.ProtocolTCP, kapi.ProtocolUDP}
+	if ovn.SCTPSupport {
+		protocols = append(protocols, kapi.ProtocolSCTP)
+	}
+	return protocols
+}
+
 // createLoadBalancerVIPs either creates or updates a set of load balancer VIPs mapping
 // from sourcePort on each IP of a given address family in sourceIPs, to targetPort on
 // each IP of the same address family in targetIPs, removing the reject ACL for any
-// source IP that is now in use.
+// source IP that is now in use. Target IPs are normalized, so that the same backend
+// is always written the same way regardless of how its address was spelled.
 func (ovn *Controller) createLoadBalancerVIPs(lb string,
 	sourceIPs []string, sourcePort int32,
 	targetIPs []string, targetPort int32) error {
 	klog.V(5).Infof("Creating lb with %s, [%v], %d, [%v], %d", lb, sourceIPs, sourcePort, targetIPs, targetPort)
 
+	parsedTargetIPs := make([]net.IP, 0, len(targetIPs))
+	for _, targetIP := range targetIPs {
+		ip := net.ParseIP(targetIP)
+		if ip == nil {
+			return fmt.Errorf("invalid target IP %q for load balancer %s", targetIP, lb)
+		}
+		parsedTargetIPs = append(parsedTargetIPs, ip)
+	}
+
 	for _, sourceIP := range sourceIPs {
+		if net.ParseIP(sourceIP) == nil {
+			return fmt.Errorf("invalid source IP %q for load balancer %s", sourceIP, lb)
+		}
 		isIPv6 := utilnet.IsIPv6String(sourceIP)
 
 		var targets []string
-		for _, targetIP := range targetIPs {
-			if utilnet.IsIPv6String(targetIP) == isIPv6 {
-				targets = append(targets, util.JoinHostPortInt32(targetIP, targetPort))
+		for _, targetIP := range parsedTargetIPs {
+			if utilnet.IsIPv6(targetIP) == isIPv6 {
+				targets = append(targets, util.JoinHostPortInt32(targetIP.String(), targetPort))
 			}
 		}
 		err := ovn.configureLoadBalancer(lb, sourceIP, sourcePort, targets)
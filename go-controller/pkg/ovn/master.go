@@ -216,6 +216,7 @@ func (oc *Controller) StartClusterMaster(masterNodeName string) error {
 			factory.Core().V1().Pods().Informer(),
 			oc.ovnNBClient,
 			oc.ovnSBClient,
+			oc.recorder,
 		)
 		if err != nil {
 			return fmt.Errorf("failed to set up hybrid overlay master: %v", err)
@@ -431,6 +432,7 @@ func (oc *Controller) syncGatewayLogicalNetwork(node *kapi.Node, l3GatewayConfig
 	}
 
 	err = gatewayInit(node.Name, clusterSubnets, hostSubnets, joinSubnets, l3GatewayConfig, oc.SCTPSupport)
+	oc.gatewayCache.invalidate()
 	if err != nil {
 		return fmt.Errorf("failed to init shared interface gateway: %v", err)
 	}
@@ -760,7 +762,10 @@ func (oc *Controller) deleteNode(nodeName string, hostSubnets, joinSubnets []*ne
 		klog.Errorf("Error deleting node %s logical network: %v", nodeName, err)
 	}
 
-	if err := gatewayCleanup(nodeName); err != nil {
+	oc.deleteNodeVIPs(nodeName)
+	err := gatewayCleanup(nodeName)
+	oc.gatewayCache.invalidate()
+	if err != nil {
 		return fmt.Errorf("failed to clean up node %s gateway: (%v)", nodeName, err)
 	}
 
@@ -7,9 +7,14 @@ import (
 	"time"
 
 	hotypes "github.com/ovn-org/ovn-kubernetes/go-controller/hybrid-overlay/pkg/types"
+	houtil "github.com/ovn-org/ovn-kubernetes/go-controller/hybrid-overlay/pkg/util"
+	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
+	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/metrics"
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/util"
 
 	kapi "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
 	utilwait "k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog"
 )
@@ -23,25 +28,212 @@ const (
 )
 
 func (oc *Controller) syncNamespaces(namespaces []interface{}) {
-	expectedNs := make(map[string]bool)
+	// Fill the address set cache so that adding the existing namespaces
+	// doesn't have to look each address set up
+	if err := oc.addressSetFactory.PopulateCache(); err != nil {
+		klog.Errorf("Error in syncing namespaces: %v", err)
+	}
+
+	// Neither the namespace nor the network policy handlers have been added
+	// yet, so no address set can be created while the stale ones are found
+	// and deleted.
+	liveRefs, err := oc.getLiveAddressSetRefs(namespaces)
+	if err == nil {
+		err = oc.gcAddressSets(liveRefs)
+	}
+	if err != nil {
+		klog.Errorf("Failed to clean up stale address sets: %v", err)
+	}
+}
+
+const (
+	// addressSetOwnerNamespace is the owner type of a namespace's address set
+	addressSetOwnerNamespace = "Namespace"
+	// addressSetOwnerNetworkPolicy is the owner type of the peer address
+	// sets of a network policy
+	addressSetOwnerNetworkPolicy = "NetworkPolicy"
+)
+
+// parseAddressSetOwner returns the type, namespace and name of the owner of an
+// address set, given the arguments ForEachAddressSet passes for it. A
+// namespace address set's owner name is the namespace itself.
+func parseAddressSetOwner(addrSetName, namespaceName, nameSuffix string) (ownerType, ownerNamespace, ownerName string, err error) {
+	if nameSuffix == "" {
+		return addressSetOwnerNamespace, namespaceName, namespaceName, nil
+	}
+	policyNamespace, policyName, _, _, err := parsePolicyAddressSetName(addrSetName)
+	if err != nil {
+		return "", "", "", err
+	}
+	return addressSetOwnerNetworkPolicy, policyNamespace, policyName, nil
+}
+
+// addressSetsForOwner returns the names of the address sets in ownerNamespace
+// whose owner is of the given type, ie the namespace's own address set or the
+// address sets of all its network policies. Address sets whose names can't be
+// parsed unambiguously are skipped.
+func (oc *Controller) addressSetsForOwner(ownerType, ownerNamespace string) ([]string, error) {
+	var names []string
+	err := oc.addressSetFactory.ForEachAddressSet(func(addrSetName, namespaceName, nameSuffix string) {
+		if namespaceName != ownerNamespace {
+			return
+		}
+		asOwnerType, _, _, err := parseAddressSetOwner(addrSetName, namespaceName, nameSuffix)
+		if err != nil {
+			klog.V(5).Infof("Skipping address set: %v", err)
+			return
+		}
+		if asOwnerType == ownerType {
+			names = append(names, addrSetName)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+// getLiveAddressSetRefs returns the owners of the address sets that should
+// exist, in the form expected by gcAddressSets, given the namespaces passed to
+// syncNamespaces
+func (oc *Controller) getLiveAddressSetRefs(namespaces []interface{}) (map[string]bool, error) {
+	policies, err := oc.watchFactory.GetNetworkPolicies()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list network policies: %v", err)
+	}
+	liveRefs := make(map[string]bool, len(namespaces)+len(policies))
 	for _, nsInterface := range namespaces {
 		ns, ok := nsInterface.(*kapi.Namespace)
 		if !ok {
-			klog.Errorf("Spurious object in syncNamespaces: %v", nsInterface)
-			continue
+			return nil, fmt.Errorf("spurious object in syncNamespaces: %v", nsInterface)
 		}
-		expectedNs[ns.Name] = true
+		liveRefs[ns.Name] = true
+	}
+	for _, policy := range policies {
+		liveRefs[policy.Namespace+"/"+policy.Name] = true
 	}
+	return liveRefs, nil
+}
 
-	err := oc.addressSetFactory.ForEachAddressSet(func(addrSetName, namespaceName, nameSuffix string) {
-		if nameSuffix == "" && !expectedNs[namespaceName] {
-			if err := oc.addressSetFactory.DestroyAddressSetInBackingStore(addrSetName); err != nil {
-				klog.Errorf(err.Error())
-			}
+// gcAddressSets deletes every address set whose owner is not in liveRefs,
+// along with the port group of a deleted network policy. Owners are namespace
+// names for namespace address sets and "namespace/policy" for network policy
+// address sets. Address sets whose names can't be parsed unambiguously are
+// left alone, and in dry-run mode (--address-set-gc-dry-run, the default)
+// stale address sets are only logged.
+func (oc *Controller) gcAddressSets(liveRefs map[string]bool) error {
+	dryRun := config.OVNKubernetesFeature.AddressSetGCDryRun
+	return oc.addressSetFactory.ForEachAddressSet(func(addrSetName, namespaceName, nameSuffix string) {
+		ownerType, ownerNamespace, ownerName, err := parseAddressSetOwner(addrSetName, namespaceName, nameSuffix)
+		if err != nil {
+			klog.Warningf("Not garbage collecting address set: %v", err)
+			return
+		}
+		ref := ownerNamespace
+		portGroupName := ""
+		if ownerType == addressSetOwnerNetworkPolicy {
+			ref = ownerNamespace + "/" + ownerName
+			portGroupName = fmt.Sprintf("%s_%s", ownerNamespace, ownerName)
+		}
+		if liveRefs[ref] {
+			return
+		}
+		if dryRun {
+			klog.Infof("Address set %q belongs to deleted %q; not deleting it (dry run)", addrSetName, ref)
+			return
+		}
+		klog.Infof("Deleting address set %q belonging to deleted %q", addrSetName, ref)
+		if portGroupName != "" {
+			deletePortGroup(hashedPortGroup(portGroupName))
+		}
+		if err := oc.addressSetFactory.DestroyAddressSetInBackingStore(addrSetName); err != nil {
+			klog.Errorf(err.Error())
 		}
 	})
+}
+
+// addressSetSize returns the number of addresses in the address set of the
+// given namespace
+func (oc *Controller) addressSetSize(namespace string) (int, error) {
+	nsInfo := oc.getNamespaceLocked(namespace)
+	if nsInfo == nil {
+		return 0, fmt.Errorf("namespace %s not found", namespace)
+	}
+	defer nsInfo.Unlock()
+
+	if nsInfo.addressSet == nil {
+		return 0, fmt.Errorf("namespace %s has no address set", namespace)
+	}
+	return nsInfo.addressSet.Size(), nil
+}
+
+// getNamespacePodIPs returns the IPs of the pods of the given namespace that
+// have logical ports, ie the IPs its address set should contain
+func (oc *Controller) getNamespacePodIPs(namespace string) (sets.String, error) {
+	pods, err := oc.watchFactory.GetPods(namespace)
 	if err != nil {
-		klog.Errorf("Error in syncing namespaces: %v", err)
+		return nil, fmt.Errorf("failed to get pods of namespace %s: %v", namespace, err)
+	}
+	ips := sets.NewString()
+	for _, pod := range pods {
+		if pod.Spec.HostNetwork {
+			continue
+		}
+		portInfo, err := oc.logicalPortCache.get(podLogicalPortName(pod))
+		if err != nil || !portInfo.expires.IsZero() {
+			// not set up yet, or being deleted
+			continue
+		}
+		for _, ip := range createIPAddressSlice(portInfo.ips) {
+			ips.Insert(ip.String())
+		}
+	}
+	return ips, nil
+}
+
+// reconcileAddressSet updates the address set of the given namespace to
+// contain exactly the desired IPs, adding and removing only those that differ
+// from what is currently in the northbound database. The namespace is only
+// locked to look up its address set; the database is read and updated under
+// the address set's own lock.
+func (oc *Controller) reconcileAddressSet(namespace string, desired sets.String) error {
+	nsInfo := oc.getNamespaceLocked(namespace)
+	if nsInfo == nil {
+		return fmt.Errorf("namespace %s not found", namespace)
+	}
+	addressSet := nsInfo.addressSet
+	nsInfo.Unlock()
+
+	if addressSet == nil {
+		return fmt.Errorf("namespace %s has no address set", namespace)
+	}
+	ips := make([]net.IP, 0, desired.Len())
+	for _, ipStr := range desired.List() {
+		ip := net.ParseIP(ipStr)
+		if ip == nil {
+			return fmt.Errorf("invalid IP %q for the address set of namespace %s", ipStr, namespace)
+		}
+		ips = append(ips, ip)
+	}
+	return addressSet.ReconcileIPs(ips)
+}
+
+// recordAddressSetSizes samples the address set size of every namespace into
+// the namespace address set size metric. It is run periodically rather than
+// on every address set change to keep pod add/delete fast.
+func (oc *Controller) recordAddressSetSizes() {
+	namespaces, err := oc.watchFactory.GetNamespaces()
+	if err != nil {
+		klog.Errorf("Failed to get namespaces: %v", err)
+		return
+	}
+	for _, ns := range namespaces {
+		size, err := oc.addressSetSize(ns.Name)
+		if err != nil {
+			klog.V(5).Infof("Not recording address set size: %v", err)
+			continue
+		}
+		metrics.RecordNamespaceAddressSetSize(ns.Name, size)
 	}
 }
 
@@ -52,6 +244,9 @@ func (oc *Controller) addPodToNamespace(ns string, portInfo *lpInfo) error {
 	}
 	defer nsInfo.Unlock()
 
+	if nsInfo.addressSet == nil {
+		return fmt.Errorf("namespace %s has no address set", ns)
+	}
 	if err := nsInfo.addressSet.AddIPs(createIPAddressSlice(portInfo.ips)); err != nil {
 		return err
 	}
@@ -74,6 +269,9 @@ func (oc *Controller) deletePodFromNamespace(ns string, portInfo *lpInfo) error
 	}
 	defer nsInfo.Unlock()
 
+	if nsInfo.addressSet == nil {
+		return fmt.Errorf("namespace %s has no address set", ns)
+	}
 	if err := nsInfo.addressSet.DeleteIPs(createIPAddressSlice(portInfo.ips)); err != nil {
 		return err
 	}
@@ -159,21 +357,264 @@ func (nsInfo *namespaceInfo) updateNamespacePortGroup(ns string) error {
 	return nil
 }
 
-func parseRoutingExternalGWAnnotation(annotation string) ([]net.IP, error) {
+// lookupGatewayHost resolves an external gateway hostname. It is a variable so
+// that tests can replace it.
+var lookupGatewayHost = net.LookupIP
+
+// parseRoutingExternalGWAnnotation parses a routing-external-gws annotation,
+// which is a comma-separated list of IPs and hostnames. It returns the IPs and
+// the hostnames separately, as the hostnames must be resolved (and resolved
+// again periodically) to get their IPs.
+func parseRoutingExternalGWAnnotation(annotation string) ([]net.IP, []string, error) {
 	var routingExternalGWs []net.IP
+	var routingExternalGWHosts []string
 	for _, v := range strings.Split(annotation, ",") {
-		parsedAnnotation := net.ParseIP(v)
-		if parsedAnnotation == nil {
-			return nil, fmt.Errorf("could not parse routing external gw annotation value %s", v)
+		if parsedAnnotation := net.ParseIP(v); parsedAnnotation != nil {
+			routingExternalGWs = append(routingExternalGWs, parsedAnnotation)
+		} else if len(validation.IsDNS1123Subdomain(v)) == 0 && !hasNumericLastLabel(v) {
+			routingExternalGWHosts = append(routingExternalGWHosts, v)
+		} else {
+			return nil, nil, fmt.Errorf("could not parse routing external gw annotation value %s", v)
+		}
+	}
+	return routingExternalGWs, routingExternalGWHosts, nil
+}
+
+// hasNumericLastLabel returns whether the last label of name is all digits.
+// No top-level domain is numeric, so such a name is a malformed IP address
+// (eg, "10.0.0.256") rather than a hostname.
+func hasNumericLastLabel(name string) bool {
+	label := name[strings.LastIndex(name, ".")+1:]
+	for _, c := range label {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveGatewayHosts returns the IPs that each of hosts currently resolves
+// to. A hostname that fails to resolve is logged and keeps the IPs it resolved
+// to in lastResolved, if any, so that a transient DNS failure doesn't withdraw
+// its routes; it doesn't prevent the other gateways from being used either.
+func resolveGatewayHosts(hosts []string, lastResolved map[string][]net.IP) map[string][]net.IP {
+	resolved := make(map[string][]net.IP, len(hosts))
+	for _, host := range hosts {
+		hostIPs, err := lookupGatewayHost(host)
+		if err != nil {
+			klog.Errorf("Could not resolve routing external gw %s, keeping its last known IPs %v: %v",
+				host, lastResolved[host], err)
+			hostIPs = lastResolved[host]
+		}
+		if len(hostIPs) > 0 {
+			resolved[host] = hostIPs
+		}
+	}
+	return resolved
+}
+
+// gatewayHostIPs returns the IPs that hosts resolved to, in the order of hosts
+func gatewayHostIPs(hosts []string, hostIPs map[string][]net.IP) []net.IP {
+	var ips []net.IP
+	for _, host := range hosts {
+		ips = append(ips, hostIPs[host]...)
+	}
+	return ips
+}
+
+// routingExternalGWs is a parsed routing-external-gws annotation, with its
+// hostnames resolved
+type routingExternalGWs struct {
+	ips     []net.IP
+	hosts   []string
+	hostIPs map[string][]net.IP
+	gws     []net.IP
+}
+
+// resolveRoutingExternalGWs parses a routing-external-gws annotation and
+// resolves any hostnames in it. It may wait for DNS, so it must not be called
+// with a namespace locked.
+func resolveRoutingExternalGWs(annotation string) (*routingExternalGWs, error) {
+	if annotation == "" {
+		return &routingExternalGWs{}, nil
+	}
+	ips, hosts, err := parseRoutingExternalGWAnnotation(annotation)
+	if err != nil {
+		return &routingExternalGWs{}, err
+	}
+	hostIPs := resolveGatewayHosts(hosts, nil)
+	return &routingExternalGWs{
+		ips:     ips,
+		hosts:   hosts,
+		hostIPs: hostIPs,
+		gws:     mergeGatewayIPs(ips, gatewayHostIPs(hosts, hostIPs)),
+	}, nil
+}
+
+// setRoutingExternalGWs stores the resolved routing-external-gws annotation
+// gws in nsInfo. nsInfo must be locked.
+func setRoutingExternalGWs(nsInfo *namespaceInfo, gws *routingExternalGWs) {
+	nsInfo.routingExternalGWIPs = gws.ips
+	nsInfo.routingExternalGWHosts = gws.hosts
+	nsInfo.routingExternalGWHostIPs = gws.hostIPs
+	nsInfo.routingExternalGWs = gws.gws
+}
+
+// mergeGatewayIPs returns the IPs in a and b, without duplicates
+func mergeGatewayIPs(a, b []net.IP) []net.IP {
+	var merged []net.IP
+	seen := make(map[string]bool)
+	for _, ips := range [][]net.IP{a, b} {
+		for _, ip := range ips {
+			if !seen[ip.String()] {
+				seen[ip.String()] = true
+				merged = append(merged, ip)
+			}
+		}
+	}
+	return merged
+}
+
+// diffGatewayIPs returns the IPs that are in a but not in b
+func diffGatewayIPs(a, b []net.IP) []net.IP {
+	var diff []net.IP
+	for _, ip := range a {
+		found := false
+		for _, other := range b {
+			if ip.Equal(other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			diff = append(diff, ip)
+		}
+	}
+	return diff
+}
+
+// recordExternalGWNextHops updates the external gateway next hop metric of the
+// namespace, counting both the annotation's and the gateway pods' next hops.
+// nsInfo must be locked.
+func recordExternalGWNextHops(namespace string, nsInfo *namespaceInfo) {
+	gws := nsInfo.routingExternalGWs
+	for _, podGWs := range nsInfo.routingExternalPodGWs {
+		gws = mergeGatewayIPs(gws, podGWs)
+	}
+	metrics.RecordExternalGatewayNextHops(namespace, len(gws))
+}
+
+// addRoutingExternalGWRoutes adds src-ip routes via gws to the gateway
+// routers for all the existing pods in the namespace. nsInfo must be locked.
+func (oc *Controller) addRoutingExternalGWRoutes(namespace string, nsInfo *namespaceInfo, gws []net.IP) {
+	existingPods, err := oc.watchFactory.GetPods(namespace)
+	if err != nil {
+		klog.Errorf("Failed to get all the pods (%v)", err)
+		return
+	}
+	for _, pod := range existingPods {
+		gr := "GR_" + pod.Spec.NodeName
+		for _, gw := range gws {
+			for _, podIP := range pod.Status.PodIPs {
+				mask := GetIPFullMask(podIP.IP)
+				_, stderr, err := util.RunOVNNbctl("--", "--may-exist", "--policy=src-ip", "--ecmp",
+					"lr-route-add", gr, podIP.IP+mask, gw.String())
+				if err != nil {
+					klog.Errorf("Unable to add src-ip route to GR router, stderr:%q, err:%v", stderr, err)
+					metrics.RecordExternalGatewayRouteError(namespace)
+				} else {
+					if nsInfo.podExternalRoutes[podIP.IP] == nil {
+						nsInfo.podExternalRoutes[podIP.IP] = make(map[string]string)
+					}
+					nsInfo.podExternalRoutes[podIP.IP][gw.String()] = gr
+				}
+			}
+		}
+	}
+}
+
+// deleteRoutingExternalGWRoutes deletes the src-ip routes via gws that were
+// added for the pods in the namespace. nsInfo must be locked.
+func deleteRoutingExternalGWRoutes(namespace string, nsInfo *namespaceInfo, gws []net.IP) {
+	for _, gw := range gws {
+		for podIP, gwToGr := range nsInfo.podExternalRoutes {
+			gr := gwToGr[gw.String()]
+			if gr == "" {
+				continue
+			}
+			mask := GetIPFullMask(podIP)
+			_, stderr, err := util.RunOVNNbctl("--", "--if-exists", "--policy=src-ip",
+				"lr-route-del", gr, podIP+mask, gw.String())
+			if err != nil {
+				klog.Errorf("Unable to delete src-ip route to GR router, stderr:%q, err:%v", stderr, err)
+				metrics.RecordExternalGatewayRouteError(namespace)
+				continue
+			}
+			delete(gwToGr, gw.String())
+			if len(gwToGr) == 0 {
+				delete(nsInfo.podExternalRoutes, podIP)
+			}
+		}
+	}
+}
+
+// refreshRoutingExternalGWHosts resolves the hostnames in all namespaces'
+// routing-external-gws annotations again, and updates the namespaces' routes
+// if the IPs they resolve to have changed. A hostname that fails to resolve
+// keeps its routes via the IPs it last resolved to.
+func (oc *Controller) refreshRoutingExternalGWHosts() {
+	namespaces, err := oc.watchFactory.GetNamespaces()
+	if err != nil {
+		klog.Errorf("Failed to get namespaces: %v", err)
+		return
+	}
+	for _, ns := range namespaces {
+		nsInfo := oc.getNamespaceLocked(ns.Name)
+		if nsInfo == nil {
+			continue
 		}
-		routingExternalGWs = append(routingExternalGWs, parsedAnnotation)
+		hosts := nsInfo.routingExternalGWHosts
+		lastResolved := nsInfo.routingExternalGWHostIPs
+		nsInfo.Unlock()
+		if len(hosts) == 0 {
+			continue
+		}
+
+		// Don't hold the namespace lock while waiting for DNS
+		resolved := resolveGatewayHosts(hosts, lastResolved)
+
+		nsInfo = oc.getNamespaceLocked(ns.Name)
+		if nsInfo == nil {
+			continue
+		}
+		if strings.Join(nsInfo.routingExternalGWHosts, ",") == strings.Join(hosts, ",") {
+			nsInfo.routingExternalGWHostIPs = resolved
+			gws := mergeGatewayIPs(nsInfo.routingExternalGWIPs, gatewayHostIPs(hosts, resolved))
+			added := diffGatewayIPs(gws, nsInfo.routingExternalGWs)
+			removed := diffGatewayIPs(nsInfo.routingExternalGWs, gws)
+			if len(added) > 0 || len(removed) > 0 {
+				klog.Infof("Routing external gws for namespace %s changed from %v to %v",
+					ns.Name, nsInfo.routingExternalGWs, gws)
+				deleteRoutingExternalGWRoutes(ns.Name, nsInfo, removed)
+				oc.addRoutingExternalGWRoutes(ns.Name, nsInfo, added)
+				nsInfo.routingExternalGWs = gws
+				recordExternalGWNextHops(ns.Name, nsInfo)
+			}
+		}
+		nsInfo.Unlock()
 	}
-	return routingExternalGWs, nil
 }
 
 // AddNamespace creates corresponding addressset in ovn db
 func (oc *Controller) AddNamespace(ns *kapi.Namespace) {
 	klog.V(5).Infof("Adding namespace: %s", ns.Name)
+
+	// Don't hold the namespace lock while waiting for DNS
+	routingGWs, err := resolveRoutingExternalGWs(ns.Annotations[routingExternalGWsAnnotation])
+	if err != nil {
+		klog.Errorf(err.Error())
+	}
+
 	nsInfo := oc.createNamespaceLocked(ns.Name)
 	defer nsInfo.Unlock()
 
@@ -199,11 +640,12 @@ func (oc *Controller) AddNamespace(ns *kapi.Namespace) {
 
 	annotation := ns.Annotations[hotypes.HybridOverlayExternalGw]
 	if annotation != "" {
-		parsedAnnotation := net.ParseIP(annotation)
-		if parsedAnnotation == nil {
-			klog.Errorf("Could not parse hybrid overlay external gw annotation")
+		// only the presence and address family of the gateways matter here
+		parsedAnnotation, err := houtil.ParseExternalGws(annotation)
+		if err != nil {
+			klog.Errorf("Could not parse hybrid overlay external gw annotation: %v", err)
 		} else {
-			nsInfo.hybridOverlayExternalGW = parsedAnnotation
+			nsInfo.hybridOverlayExternalGW = parsedAnnotation[0]
 		}
 	}
 	annotation = ns.Annotations[hotypes.HybridOverlayVTEP]
@@ -215,16 +657,11 @@ func (oc *Controller) AddNamespace(ns *kapi.Namespace) {
 			nsInfo.hybridOverlayVTEP = parsedAnnotation
 		}
 	}
-	annotation = ns.Annotations[routingExternalGWsAnnotation]
-	if annotation != "" {
-		nsInfo.routingExternalGWs, err = parseRoutingExternalGWAnnotation(annotation)
-		if err != nil {
-			klog.Errorf(err.Error())
-		}
-	}
+	setRoutingExternalGWs(nsInfo, routingGWs)
+	recordExternalGWNextHops(ns.Name, nsInfo)
 	nsInfo.addressSet, err = oc.addressSetFactory.NewAddressSet(ns.Name, ips)
 	if err != nil {
-		klog.Errorf(err.Error())
+		klog.Errorf("Failed to create address set for namespace %s: %v", ns.Name, err)
 	}
 
 	oc.multicastUpdateNamespace(ns, nsInfo)
@@ -233,6 +670,34 @@ func (oc *Controller) AddNamespace(ns *kapi.Namespace) {
 func (oc *Controller) updateNamespace(old, newer *kapi.Namespace) {
 	klog.V(5).Infof("Updating namespace: %s", old.Name)
 
+	if old.ResourceVersion == newer.ResourceVersion {
+		// This is a periodic resync rather than a change; use it to repair
+		// any drift between the namespace's pods and its address set. The
+		// pod IPs are read without the namespace lock, so a pod set up or
+		// torn down concurrently can leave the set out of date until the
+		// next resync.
+		desired, err := oc.getNamespacePodIPs(newer.Name)
+		if err == nil {
+			err = oc.reconcileAddressSet(newer.Name, desired)
+		}
+		if err != nil {
+			klog.Errorf("Failed to reconcile address set of namespace %s: %v", newer.Name, err)
+		}
+	}
+
+	var annotation, oldAnnotation string
+	annotation = newer.Annotations[routingExternalGWsAnnotation]
+	oldAnnotation = old.Annotations[routingExternalGWsAnnotation]
+	var routingGWs *routingExternalGWs
+	if annotation != oldAnnotation {
+		// Don't hold the namespace lock while waiting for DNS
+		var err error
+		routingGWs, err = resolveRoutingExternalGWs(annotation)
+		if err != nil {
+			klog.Errorf(err.Error())
+		}
+	}
+
 	nsInfo := oc.getNamespaceLocked(old.Name)
 	if nsInfo == nil {
 		klog.Warningf("Update event for unknown namespace %q", old.Name)
@@ -240,10 +705,7 @@ func (oc *Controller) updateNamespace(old, newer *kapi.Namespace) {
 	}
 	defer nsInfo.Unlock()
 
-	var annotation, oldAnnotation string
-	annotation = newer.Annotations[routingExternalGWsAnnotation]
-	oldAnnotation = old.Annotations[routingExternalGWsAnnotation]
-	if annotation != oldAnnotation {
+	if routingGWs != nil {
 		var stderr string
 		var err error
 		for podIP, gwToGr := range nsInfo.podExternalRoutes {
@@ -253,51 +715,26 @@ func (oc *Controller) updateNamespace(old, newer *kapi.Namespace) {
 					"lr-route-del", gr, podIP+mask, gw)
 				if err != nil {
 					klog.Errorf("Unable to delete src-ip route to GR router, stderr:%q, err:%v", stderr, err)
+					metrics.RecordExternalGatewayRouteError(old.Name)
 				} else {
 					delete(nsInfo.podExternalRoutes, podIP)
 				}
 			}
 		}
-		nsInfo.routingExternalGWs = nil
-		if annotation != "" {
-			nsInfo.routingExternalGWs, err = parseRoutingExternalGWAnnotation(annotation)
-			if err != nil {
-				klog.Errorf(err.Error())
-			}
-			if nsInfo.routingExternalGWs != nil {
-				existingPods, err := oc.watchFactory.GetPods(old.Name)
-				if err != nil {
-					klog.Errorf("Failed to get all the pods (%v)", err)
-				} else {
-					for _, pod := range existingPods {
-						gr := "GR_" + pod.Spec.NodeName
-						for _, gw := range nsInfo.routingExternalGWs {
-							for _, podIP := range pod.Status.PodIPs {
-								mask := GetIPFullMask(podIP.IP)
-								_, stderr, err = util.RunOVNNbctl("--", "--may-exist", "--policy=src-ip", "--ecmp",
-									"lr-route-add", gr, podIP.IP+mask, gw.String())
-								if err != nil {
-									klog.Errorf("Unable to add src-ip route to GR router, stderr:%q, err:%v", stderr, err)
-								} else {
-									if nsInfo.podExternalRoutes[podIP.IP] == nil {
-										nsInfo.podExternalRoutes[podIP.IP] = make(map[string]string)
-									}
-									nsInfo.podExternalRoutes[podIP.IP][gw.String()] = gr
-								}
-							}
-						}
-					}
-				}
-			}
+		setRoutingExternalGWs(nsInfo, routingGWs)
+		if nsInfo.routingExternalGWs != nil {
+			oc.addRoutingExternalGWRoutes(old.Name, nsInfo, nsInfo.routingExternalGWs)
 		}
+		recordExternalGWNextHops(old.Name, nsInfo)
 	}
 	annotation = newer.Annotations[hotypes.HybridOverlayExternalGw]
 	if annotation != "" {
-		parsedAnnotation := net.ParseIP(annotation)
-		if parsedAnnotation == nil {
-			klog.Errorf("Could not parse hybrid overlay external gw annotation")
+		// only the presence and address family of the gateways matter here
+		parsedAnnotation, err := houtil.ParseExternalGws(annotation)
+		if err != nil {
+			klog.Errorf("Could not parse hybrid overlay external gw annotation: %v", err)
 		} else {
-			nsInfo.hybridOverlayExternalGW = parsedAnnotation
+			nsInfo.hybridOverlayExternalGW = parsedAnnotation[0]
 		}
 	} else {
 		nsInfo.hybridOverlayExternalGW = nil
@@ -325,7 +762,22 @@ func (oc *Controller) deleteNamespace(ns *kapi.Namespace) {
 	}
 	defer nsInfo.Unlock()
 
+	if nsInfo.addressSet == nil {
+		// Creating the namespace's address set failed, but it may have left
+		// the address set of one IP family behind
+		names, err := oc.addressSetsForOwner(addressSetOwnerNamespace, ns.Name)
+		if err != nil {
+			klog.Errorf("Failed to list address sets of namespace %s: %v", ns.Name, err)
+		}
+		for _, name := range names {
+			if err := oc.addressSetFactory.DestroyAddressSetInBackingStore(name); err != nil {
+				klog.Errorf(err.Error())
+			}
+		}
+	}
 	oc.multicastDeleteNamespace(ns, nsInfo)
+	metrics.DeleteExternalGatewayMetrics(ns.Name)
+	metrics.DeleteNamespaceAddressSetSize(ns.Name)
 }
 
 // waitForNamespaceLocked waits up to 10 seconds for a Namespace to be known; use this
@@ -411,8 +863,10 @@ func (oc *Controller) deleteNamespaceLocked(ns string) *namespaceInfo {
 		nsInfo.Unlock()
 		return nil
 	}
-	if err := nsInfo.addressSet.Destroy(); err != nil {
-		klog.Errorf(err.Error())
+	if nsInfo.addressSet != nil {
+		if err := nsInfo.addressSet.Destroy(); err != nil {
+			klog.Errorf(err.Error())
+		}
 	}
 	delete(oc.namespaces, ns)
 
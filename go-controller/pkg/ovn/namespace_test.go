@@ -2,7 +2,10 @@ package ovn
 
 import (
 	"context"
+	"fmt"
 	"net"
+	"sync/atomic"
+	"time"
 
 	"github.com/urfave/cli/v2"
 
@@ -10,8 +13,10 @@ import (
 	ovntest "github.com/ovn-org/ovn-kubernetes/go-controller/pkg/testing"
 
 	v1 "k8s.io/api/core/v1"
+	knet "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -141,9 +146,211 @@ var _ = Describe("OVN Namespace Operations", func() {
 			err := app.Run([]string{app.Name})
 			Expect(err).NotTo(HaveOccurred())
 		})
+		It("lists the address sets of a namespace by owner type", func() {
+			app.Action = func(ctx *cli.Context) error {
+				fakeOvn.start(ctx)
+
+				policyASNames := []string{
+					getPolicyAddressSetName(namespaceName, "allow.from.monitoring", knet.PolicyTypeIngress, 0),
+					getPolicyAddressSetName(namespaceName, "deny-all", knet.PolicyTypeEgress, 1),
+				}
+				for _, name := range append([]string{
+					namespaceName,
+					"other-namespace",
+					getPolicyAddressSetName("other-namespace", "deny-all", knet.PolicyTypeIngress, 0),
+					// ambiguous, so never listed
+					namespaceName + ".unknown",
+				}, policyASNames...) {
+					_, err := fakeOvn.asf.NewAddressSet(name, nil)
+					Expect(err).NotTo(HaveOccurred())
+				}
+
+				names, err := fakeOvn.controller.addressSetsForOwner(addressSetOwnerNamespace, namespaceName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(names).To(ConsistOf(namespaceName))
+
+				names, err = fakeOvn.controller.addressSetsForOwner(addressSetOwnerNetworkPolicy, namespaceName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(names).To(ConsistOf(policyASNames))
+
+				names, err = fakeOvn.controller.addressSetsForOwner(addressSetOwnerNetworkPolicy, "no-such-namespace")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(names).To(BeEmpty())
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		table := []struct {
+			desc   string
+			dryRun bool
+		}{
+			{"garbage collects the address sets of deleted namespaces and network policies when syncing namespaces", false},
+			{"only logs stale address sets in dry-run mode", true},
+		}
+		for _, tc := range table {
+			tc := tc
+			It(tc.desc, func() {
+				app.Action = func(ctx *cli.Context) error {
+					livePolicy := newNetworkPolicy("allow.from.monitoring", namespaceName,
+						metav1.LabelSelector{}, nil, nil)
+					fakeOvn.start(ctx,
+						&v1.NamespaceList{
+							Items: []v1.Namespace{
+								*newNamespace(namespaceName),
+							},
+						},
+						&knet.NetworkPolicyList{
+							Items: []knet.NetworkPolicy{
+								*livePolicy,
+							},
+						},
+					)
+					config.OVNKubernetesFeature.AddressSetGCDryRun = tc.dryRun
+
+					liveASName := getPolicyAddressSetName(namespaceName, livePolicy.Name, knet.PolicyTypeIngress, 0)
+					staleASName := getPolicyAddressSetName(namespaceName, "deleted", knet.PolicyTypeEgress, 1)
+					for _, name := range []string{
+						namespaceName,
+						"deleted-namespace",
+						liveASName,
+						staleASName,
+						// ambiguous, so never deleted
+						namespaceName + ".unknown",
+					} {
+						_, err := fakeOvn.asf.NewAddressSet(name, nil)
+						Expect(err).NotTo(HaveOccurred())
+					}
+					if !tc.dryRun {
+						fakeOvn.fakeExec.AddFakeCmdsNoOutputNoError([]string{
+							"ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find port_group name=" +
+								hashedPortGroup(namespaceName+"_deleted"),
+						})
+					}
+
+					// the stale address sets are collected before the
+					// namespace handlers are added
+					fakeOvn.controller.WatchNamespaces()
+
+					fakeOvn.asf.ExpectEmptyAddressSet(v4AddressSetName)
+					fakeOvn.asf.ExpectEmptyAddressSet(getIPv4ASName(liveASName))
+					fakeOvn.asf.ExpectEmptyAddressSet(getIPv4ASName(namespaceName + ".unknown"))
+					if tc.dryRun {
+						fakeOvn.asf.ExpectEmptyAddressSet(getIPv4ASName("deleted-namespace"))
+						fakeOvn.asf.ExpectEmptyAddressSet(getIPv4ASName(staleASName))
+					} else {
+						fakeOvn.asf.ExpectNoAddressSet(getIPv4ASName("deleted-namespace"))
+						fakeOvn.asf.ExpectNoAddressSet(getIPv4ASName(staleASName))
+					}
+					Expect(fakeOvn.fakeExec.CalledMatchesExpected()).To(BeTrue(), fakeOvn.fakeExec.ErrorDesc)
+					return nil
+				}
+
+				err := app.Run([]string{app.Name})
+				Expect(err).NotTo(HaveOccurred())
+			})
+		}
 	})
 
 	Context("during execution", func() {
+		It("reports the size of a namespace's address set", func() {
+			app.Action = func(ctx *cli.Context) error {
+				fakeOvn.start(ctx, &v1.NamespaceList{
+					Items: []v1.Namespace{
+						*newNamespace(namespaceName),
+					},
+				})
+				fakeOvn.controller.WatchNamespaces()
+
+				size, err := fakeOvn.controller.addressSetSize(namespaceName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(size).To(Equal(0))
+
+				nsInfo := fakeOvn.controller.getNamespaceLocked(namespaceName)
+				Expect(nsInfo).NotTo(BeNil())
+				err = nsInfo.addressSet.AddIPs(ovntest.MustParseIPs("10.128.1.3", "10.128.1.4"))
+				nsInfo.Unlock()
+				Expect(err).NotTo(HaveOccurred())
+
+				size, err = fakeOvn.controller.addressSetSize(namespaceName)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(size).To(Equal(2))
+
+				_, err = fakeOvn.controller.addressSetSize("no-such-namespace")
+				Expect(err).To(HaveOccurred())
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("reconciles a namespace's address set with its pods on resync", func() {
+			app.Action = func(ctx *cli.Context) error {
+				namespaceT := *newNamespace(namespaceName)
+				namespaceT.ResourceVersion = "1"
+				tP := newTPod(
+					"node1",
+					"10.128.1.0/24",
+					"10.128.1.2",
+					"10.128.1.1",
+					"myPod",
+					"10.128.1.4",
+					"11:22:33:44:55:66",
+					namespaceT.Name,
+				)
+				fakeOvn.start(ctx,
+					&v1.NamespaceList{
+						Items: []v1.Namespace{
+							namespaceT,
+						},
+					},
+					&v1.PodList{
+						Items: []v1.Pod{
+							*newPod(namespaceT.Name, tP.podName, tP.nodeName, tP.podIP),
+							// has no logical port, so resync removes its IP
+							*newPod(namespaceT.Name, "newPod", tP.nodeName, "10.128.1.5"),
+						},
+					},
+				)
+				fakeOvn.controller.WatchNamespaces()
+
+				podMAC := ovntest.MustParseMAC(tP.podMAC)
+				podIPNets := []*net.IPNet{ovntest.MustParseIPNet(tP.podIP + "/24")}
+				fakeOvn.controller.logicalPortCache.add(tP.nodeName, tP.portName, fakeUUID, podMAC, podIPNets)
+				nsInfo := fakeOvn.controller.getNamespaceLocked(namespaceName)
+				Expect(nsInfo).NotTo(BeNil())
+				// a stale IP
+				err := nsInfo.addressSet.AddIPs(ovntest.MustParseIPs("10.128.1.3"))
+				nsInfo.Unlock()
+				Expect(err).NotTo(HaveOccurred())
+
+				// a real update doesn't touch the address set
+				updated := namespaceT.DeepCopy()
+				updated.ResourceVersion = "2"
+				fakeOvn.controller.updateNamespace(&namespaceT, updated)
+				fakeOvn.asf.ExpectAddressSetWithIPs(v4AddressSetName, []string{"10.128.1.3", tP.podIP, "10.128.1.5"})
+
+				fakeOvn.controller.updateNamespace(updated, updated)
+				fakeOvn.asf.ExpectAddressSetWithIPs(v4AddressSetName, []string{tP.podIP})
+
+				err = fakeOvn.controller.reconcileAddressSet(namespaceName, sets.NewString(tP.podIP, "10.128.1.9"))
+				Expect(err).NotTo(HaveOccurred())
+				fakeOvn.asf.ExpectAddressSetWithIPs(v4AddressSetName, []string{tP.podIP, "10.128.1.9"})
+
+				err = fakeOvn.controller.reconcileAddressSet(namespaceName, sets.NewString("not-an-ip"))
+				Expect(err).To(HaveOccurred())
+				err = fakeOvn.controller.reconcileAddressSet("no-such-namespace", sets.NewString())
+				Expect(err).To(HaveOccurred())
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
 		It("deletes an empty namespace's resources", func() {
 			app.Action = func(ctx *cli.Context) error {
 				fakeOvn.start(ctx, &v1.NamespaceList{
@@ -164,5 +371,167 @@ var _ = Describe("OVN Namespace Operations", func() {
 			err := app.Run([]string{app.Name})
 			Expect(err).NotTo(HaveOccurred())
 		})
+
+		It("deletes the leftovers of a namespace address set that failed to be created", func() {
+			app.Action = func(ctx *cli.Context) error {
+				fakeOvn.start(ctx, &v1.NamespaceList{
+					Items: []v1.Namespace{
+						*newNamespace(namespaceName),
+					},
+				})
+				fakeOvn.controller.WatchNamespaces()
+
+				nsInfo := fakeOvn.controller.getNamespaceLocked(namespaceName)
+				Expect(nsInfo).NotTo(BeNil())
+				nsInfo.addressSet = nil
+				nsInfo.Unlock()
+				fakeOvn.asf.ExpectEmptyAddressSet(v4AddressSetName)
+
+				err := fakeOvn.fakeClient.CoreV1().Namespaces().Delete(context.TODO(), namespaceName, *metav1.NewDeleteOptions(1))
+				Expect(err).NotTo(HaveOccurred())
+				fakeOvn.asf.EventuallyExpectNoAddressSet(v4AddressSetName)
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("resolves routing external gw hostnames without holding the namespace lock", func() {
+			app.Action = func(ctx *cli.Context) error {
+				var lookups, lockedLookups int32
+				origLookupGatewayHost := lookupGatewayHost
+				defer func() { lookupGatewayHost = origLookupGatewayHost }()
+				lookupGatewayHost = func(host string) ([]net.IP, error) {
+					atomic.AddInt32(&lookups, 1)
+					locked := make(chan struct{})
+					go func() {
+						if nsInfo := fakeOvn.controller.getNamespaceLocked(namespaceName); nsInfo != nil {
+							nsInfo.Unlock()
+						}
+						close(locked)
+					}()
+					select {
+					case <-locked:
+					case <-time.After(time.Second):
+						atomic.AddInt32(&lockedLookups, 1)
+					}
+					return []net.IP{net.ParseIP("9.0.0.2")}, nil
+				}
+
+				namespaceT := *newNamespace(namespaceName)
+				namespaceT.Annotations[routingExternalGWsAnnotation] = "gw.example.com"
+				fakeOvn.start(ctx, &v1.NamespaceList{
+					Items: []v1.Namespace{
+						namespaceT,
+					},
+				})
+				fakeOvn.controller.WatchNamespaces()
+
+				namespaceT.Annotations[routingExternalGWsAnnotation] = "9.0.0.1,gw.example.com"
+				_, err := fakeOvn.fakeClient.CoreV1().Namespaces().Update(context.TODO(), &namespaceT, metav1.UpdateOptions{})
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(func() []net.IP {
+					nsInfo := fakeOvn.controller.getNamespaceLocked(namespaceName)
+					Expect(nsInfo).NotTo(BeNil())
+					defer nsInfo.Unlock()
+					return nsInfo.routingExternalGWs
+				}).Should(Equal([]net.IP{net.ParseIP("9.0.0.1"), net.ParseIP("9.0.0.2")}))
+
+				Expect(atomic.LoadInt32(&lookups)).To(Equal(int32(2)))
+				Expect(atomic.LoadInt32(&lockedLookups)).To(Equal(int32(0)))
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("updates the routing external gw routes when a gateway hostname resolves differently", func() {
+			app.Action = func(ctx *cli.Context) error {
+				var resolved []net.IP
+				var resolveErr error
+				origLookupGatewayHost := lookupGatewayHost
+				defer func() { lookupGatewayHost = origLookupGatewayHost }()
+				lookupGatewayHost = func(host string) ([]net.IP, error) {
+					Expect(host).To(Equal("gw.example.com"))
+					return resolved, resolveErr
+				}
+
+				namespaceT := *newNamespace(namespaceName)
+				namespaceT.Annotations[routingExternalGWsAnnotation] = "9.0.0.1,gw.example.com"
+				fakeOvn.start(ctx,
+					&v1.NamespaceList{
+						Items: []v1.Namespace{
+							namespaceT,
+						},
+					},
+					&v1.PodList{
+						Items: []v1.Pod{
+							*newPod(namespaceName, "myPod", "node1", "10.128.1.3"),
+						},
+					},
+				)
+				fakeOvn.controller.WatchNamespaces()
+
+				nsInfo := fakeOvn.controller.getNamespaceLocked(namespaceName)
+				Expect(nsInfo).NotTo(BeNil())
+				Expect(nsInfo.routingExternalGWs).To(Equal([]net.IP{net.ParseIP("9.0.0.1")}))
+				nsInfo.Unlock()
+
+				// the hostname starts resolving
+				resolved = []net.IP{net.ParseIP("9.0.0.2")}
+				fakeOvn.fakeExec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 -- --may-exist --policy=src-ip --ecmp lr-route-add GR_node1 10.128.1.3/32 9.0.0.2",
+				})
+				fakeOvn.controller.refreshRoutingExternalGWHosts()
+				Expect(fakeOvn.fakeExec.CalledMatchesExpected()).To(BeTrue(), fakeOvn.fakeExec.ErrorDesc)
+
+				// nothing changes
+				fakeOvn.controller.refreshRoutingExternalGWHosts()
+				Expect(fakeOvn.fakeExec.CalledMatchesExpected()).To(BeTrue(), fakeOvn.fakeExec.ErrorDesc)
+
+				// the hostname moves
+				resolved = []net.IP{net.ParseIP("9.0.0.3")}
+				fakeOvn.fakeExec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 -- --if-exists --policy=src-ip lr-route-del GR_node1 10.128.1.3/32 9.0.0.2",
+					"ovn-nbctl --timeout=15 -- --may-exist --policy=src-ip --ecmp lr-route-add GR_node1 10.128.1.3/32 9.0.0.3",
+				})
+				fakeOvn.controller.refreshRoutingExternalGWHosts()
+				Expect(fakeOvn.fakeExec.CalledMatchesExpected()).To(BeTrue(), fakeOvn.fakeExec.ErrorDesc)
+
+				// a failed lookup keeps the routes via the last resolved IPs
+				resolved = nil
+				resolveErr = fmt.Errorf("temporary DNS failure")
+				fakeOvn.controller.refreshRoutingExternalGWHosts()
+				Expect(fakeOvn.fakeExec.CalledMatchesExpected()).To(BeTrue(), fakeOvn.fakeExec.ErrorDesc)
+
+				nsInfo = fakeOvn.controller.getNamespaceLocked(namespaceName)
+				Expect(nsInfo).NotTo(BeNil())
+				Expect(nsInfo.routingExternalGWs).To(Equal([]net.IP{net.ParseIP("9.0.0.1"), net.ParseIP("9.0.0.3")}))
+				Expect(nsInfo.podExternalRoutes).To(Equal(map[string]map[string]string{
+					"10.128.1.3": {"9.0.0.3": "GR_node1"},
+				}))
+				nsInfo.Unlock()
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
+
+var _ = Describe("OVN Namespace Low-Level Operations", func() {
+	It("parses IPs and hostnames in the routing external gws annotation", func() {
+		ips, hosts, err := parseRoutingExternalGWAnnotation("9.0.0.1,gw.example.com,fd00::1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ips).To(Equal([]net.IP{net.ParseIP("9.0.0.1"), net.ParseIP("fd00::1")}))
+		Expect(hosts).To(Equal([]string{"gw.example.com"}))
+
+		for _, annotation := range []string{"", "9.0.0.1,", "gw_1.example.com", "GW.example.com", "10.0.0.256", "10.0.0", "gw.example.123"} {
+			_, _, err := parseRoutingExternalGWAnnotation(annotation)
+			Expect(err).To(HaveOccurred(), annotation)
+		}
 	})
 })
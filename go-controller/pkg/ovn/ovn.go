@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"sort"
 	"sync"
 	"time"
 
@@ -32,6 +33,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	utilwait "k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/cache"
@@ -42,6 +44,9 @@ import (
 
 const (
 	egressfirewallCRD = "egressfirewalls.k8s.ovn.org"
+	// addressSetSizeSampleInterval is how often the namespace address set
+	// size metric is updated
+	addressSetSizeSampleInterval = time.Minute
 )
 
 // ServiceVIPKey is used for looking up service namespace information for a
@@ -85,8 +90,16 @@ type namespaceInfo struct {
 	hybridOverlayVTEP       net.IP
 
 	// routingExternalGWs is a slice of net.IP containing the values parsed from
-	// annotation k8s.ovn.org/routing-external-gws
+	// annotation k8s.ovn.org/routing-external-gws, with hostnames resolved
 	routingExternalGWs []net.IP
+	// routingExternalGWIPs and routingExternalGWHosts are the IPs and the
+	// hostnames in the annotation; the hostnames are periodically resolved
+	// again to update routingExternalGWs
+	routingExternalGWIPs   []net.IP
+	routingExternalGWHosts []string
+	// routingExternalGWHostIPs holds the IPs that each of the hostnames last
+	// resolved to. It is replaced rather than modified when updated.
+	routingExternalGWHostIPs map[string][]net.IP
 	// podExternalRoutes is a cache keeping the LR routes added to the GRs when
 	// the k8s.ovn.org/routing-external-gws annotation is used. The first map key
 	// is the podIP, the second the GW and the third the GR
@@ -205,6 +218,9 @@ type Controller struct {
 
 	serviceLBLock sync.Mutex
 
+	// Short-lived cache of the gateway routers and their physical IPs
+	gatewayCache gatewayCache
+
 	// event recorder used to post events to k8s
 	recorder record.EventRecorder
 
@@ -246,7 +262,7 @@ func NewOvnController(kubeClient kubernetes.Interface, egressIPClient egressipap
 	stopChan <-chan struct{}, addressSetFactory AddressSetFactory, ovnNBClient goovn.Client, ovnSBClient goovn.Client, recorder record.EventRecorder) *Controller {
 
 	if addressSetFactory == nil {
-		addressSetFactory = NewOvnAddressSetFactory()
+		addressSetFactory = NewOvnAddressSetFactory(ovnNBClient)
 	}
 	modeEgressIP := newModeEgressIP(ovnNBClient)
 	return &Controller{
@@ -315,10 +331,21 @@ func (oc *Controller) Run(wg *sync.WaitGroup) error {
 
 	klog.Infof("Completing all the Watchers took %v", time.Since(start))
 
+	// The address set cache is only worth having while the existing objects
+	// are added at startup. go-ovn reconnects to the northbound database on
+	// its own without telling us, so don't keep a cache around that a
+	// reconnect to a restored database could make stale.
+	oc.addressSetFactory.InvalidateCache()
+
 	if config.Kubernetes.OVNEmptyLbEvents {
 		go oc.ovnControllerEventChecker()
 	}
 
+	go utilwait.Until(oc.refreshRoutingExternalGWHosts,
+		time.Duration(config.Gateway.ExternalGWHostRefreshInterval)*time.Second, oc.stopChan)
+
+	go utilwait.Until(oc.recordAddressSetSizes, addressSetSizeSampleInterval, oc.stopChan)
+
 	if oc.hoMaster != nil {
 		wg.Add(1)
 		go func() {
@@ -891,11 +918,16 @@ func (oc *Controller) syncNodeGateway(node *kapi.Node, hostSubnets []*net.IPNet)
 		hostSubnets, _ = util.ParseNodeHostSubnetAnnotation(node)
 	}
 	if l3GatewayConfig.Mode == config.GatewayModeDisabled {
-		if err := gatewayCleanup(node.Name); err != nil {
+		err := gatewayCleanup(node.Name)
+		oc.gatewayCache.invalidate()
+		if err != nil {
 			return fmt.Errorf("error cleaning up gateway for node %s: %v", node.Name, err)
 		}
 	} else if hostSubnets != nil {
 		if err := oc.syncGatewayLogicalNetwork(node, l3GatewayConfig, hostSubnets); err != nil {
+			if isGatewayRouterNotFoundError(err) {
+				return err
+			}
 			return fmt.Errorf("error creating gateway for node %s: %v", node.Name, err)
 		}
 	}
@@ -940,7 +972,9 @@ func (oc *Controller) WatchNodes() {
 			}
 
 			if err := oc.syncNodeGateway(node, hostSubnets); err != nil {
-				if !util.IsAnnotationNotSetError(err) {
+				if isGatewayRouterNotFoundError(err) {
+					klog.V(5).Infof("Will retry gateway for node %s: %v", node.Name, err)
+				} else if !util.IsAnnotationNotSetError(err) {
 					klog.Warningf(err.Error())
 				}
 				gatewaysFailed.Store(node.Name, true)
@@ -958,7 +992,7 @@ func (oc *Controller) WatchNodes() {
 						namespace.Name, err)
 					continue
 				}
-				if nsInfo.egressFirewallPolicy != nil {
+				if nsInfo.egressFirewallPolicy != nil && nsInfo.addressSet != nil {
 					err = nsInfo.egressFirewallPolicy.addACLToJoinSwitch([]string{joinSwitch(node.Name)}, nsInfo.addressSet.GetIPv4HashName(), nsInfo.addressSet.GetIPv6HashName())
 					if err != nil {
 						klog.Errorf("%s", err)
@@ -1011,7 +1045,9 @@ func (oc *Controller) WatchNodes() {
 			if failed || gatewayChanged(oldNode, node) {
 				err := oc.syncNodeGateway(node, nil)
 				if err != nil {
-					if !util.IsAnnotationNotSetError(err) {
+					if isGatewayRouterNotFoundError(err) {
+						klog.V(5).Infof("Will retry gateway for node %s: %v", node.Name, err)
+					} else if !util.IsAnnotationNotSetError(err) {
 						klog.Errorf(err.Error())
 					}
 					gatewaysFailed.Store(node.Name, true)
@@ -1112,11 +1148,33 @@ func (oc *Controller) getAllACLsForServiceLB(lb string) []string {
 	return acls
 }
 
+// haveServiceLBs returns whether any load balancer has known VIPs
+func (oc *Controller) haveServiceLBs() bool {
+	oc.serviceLBLock.Lock()
+	defer oc.serviceLBLock.Unlock()
+	return len(oc.serviceLBMap) > 0
+}
+
+// getServiceLBVIPs retrieves all of the VIPs known for a given load balancer
+func (oc *Controller) getServiceLBVIPs(lb string) []string {
+	oc.serviceLBLock.Lock()
+	defer oc.serviceLBLock.Unlock()
+	var vips []string
+	for vip := range oc.serviceLBMap[lb] {
+		vips = append(vips, vip)
+	}
+	sort.Strings(vips)
+	return vips
+}
+
 // removeServiceLB removes the entire LB entry for a VIP
 func (oc *Controller) removeServiceLB(lb, vip string) {
 	oc.serviceLBLock.Lock()
 	defer oc.serviceLBLock.Unlock()
 	delete(oc.serviceLBMap[lb], vip)
+	if len(oc.serviceLBMap[lb]) == 0 {
+		delete(oc.serviceLBMap, lb)
+	}
 }
 
 // removeServiceACL removes a specific ACL associated with a load balancer and ip:port
@@ -129,6 +129,7 @@ func (oc *Controller) deleteLogicalPort(pod *kapi.Pod) {
 					"lr-route-del", gr, podIP+mask, gw)
 				if err != nil {
 					klog.Errorf("Unable to delete external gw ecmp route to GR router, stderr:%q, err:%v", stderr, err)
+					metrics.RecordExternalGatewayRouteError(pod.Namespace)
 				} else {
 					delete(nsInfo.podExternalRoutes, pod.Status.PodIP)
 				}
@@ -489,6 +490,7 @@ func (oc *Controller) addLogicalPort(pod *kapi.Pod) (err error) {
 				_, stderr, err := util.RunOVNNbctl("--may-exist", "--policy=src-ip", "--ecmp",
 					"lr-route-add", gr, podIP+mask, gw)
 				if err != nil {
+					metrics.RecordExternalGatewayRouteError(pod.Namespace)
 					return fmt.Errorf("unable to add external gw src-ip route to GR router, stderr:%q, err:%v", stderr, err)
 				}
 				nsInfo, err := oc.waitForNamespaceLocked(pod.Namespace)
@@ -675,6 +677,7 @@ func (oc *Controller) addPodExternalGW(pod *kapi.Pod) error {
 		}
 		defer nsInfo.Unlock()
 		nsInfo.routingExternalPodGWs[pod.Name] = foundGws
+		recordExternalGWNextHops(namespace, nsInfo)
 		existingPods, err := oc.watchFactory.GetPods(namespace)
 		if err != nil {
 			return fmt.Errorf("failed to get all the pods for namespace %s, error: %v", namespace, err)
@@ -689,6 +692,7 @@ func (oc *Controller) addPodExternalGW(pod *kapi.Pod) error {
 						"lr-route-add", gr, podIP.IP+mask, gwIP.String())
 					if err != nil {
 						klog.Errorf("Unable to add pod ecmp src-ip route to GR router, stderr:%q, err:%v", stderr, err)
+						metrics.RecordExternalGatewayRouteError(namespace)
 					} else {
 						klog.V(5).Infof("ECMP route added for pod: %s, on gr: %s, to gw: %s", pod.Name,
 							gr, gwIP.String())
@@ -742,6 +746,7 @@ func (oc *Controller) deletePodExternalGW(pod *kapi.Pod) {
 				if err != nil {
 					klog.Errorf("Unable to delete pod %s route to GR %s, GW: %s, stderr:%q, err:%v",
 						pod.Name, gr, gwIP.String(), stderr, err)
+					metrics.RecordExternalGatewayRouteError(namespace)
 				} else {
 					klog.V(5).Infof("ECMP route deleted for pod: %s, on gr: %s, to gw: %s", pod.Name,
 						gr, gwIP.String())
@@ -754,6 +759,7 @@ func (oc *Controller) deletePodExternalGW(pod *kapi.Pod) {
 			}
 		}
 		delete(nsInfo.routingExternalPodGWs, pod.Name)
+		recordExternalGWNextHops(namespace, nsInfo)
 		nsInfo.Unlock()
 		klog.Infof("pod: %s, removed as external gateway for namespace %s", pod.Name, namespace)
 	}
@@ -10,6 +10,7 @@ import (
 	kapi "k8s.io/api/core/v1"
 	knet "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/klog"
 	utilnet "k8s.io/utils/net"
@@ -58,6 +59,10 @@ const (
 	defaultMcastAllowPriority = "1012"
 )
 
+// syncNetworkPolicies deletes the port groups, and with them the ACLs, of
+// network policies that no longer exist. Their address sets are deleted, or
+// only logged in dry-run mode, by gcAddressSets when the namespaces are
+// synced.
 func (oc *Controller) syncNetworkPolicies(networkPolicies []interface{}) {
 	expectedPolicies := make(map[string]map[string]bool)
 	for _, npInterface := range networkPolicies {
@@ -77,21 +82,25 @@ func (oc *Controller) syncNetworkPolicies(networkPolicies []interface{}) {
 		}
 	}
 
-	err := oc.addressSetFactory.ForEachAddressSet(func(addrSetName, namespaceName, policyName string) {
-		if policyName != "" && !expectedPolicies[namespaceName][policyName] {
-			// policy doesn't exist on k8s. Delete the port group
-			portGroupName := fmt.Sprintf("%s_%s", namespaceName, policyName)
-			hashedLocalPortGroup := hashedPortGroup(portGroupName)
-			deletePortGroup(hashedLocalPortGroup)
-
-			// delete the address sets for this old policy from OVN
-			if err := oc.addressSetFactory.DestroyAddressSetInBackingStore(addrSetName); err != nil {
-				klog.Errorf(err.Error())
-			}
+	stalePortGroups := sets.NewString()
+	err := oc.addressSetFactory.ForEachAddressSet(func(addrSetName, namespaceName, nameSuffix string) {
+		ownerType, ownerNamespace, ownerName, err := parseAddressSetOwner(addrSetName, namespaceName, nameSuffix)
+		if err != nil {
+			klog.Warningf("Not syncing address set: %v", err)
+			return
+		}
+		if ownerType != addressSetOwnerNetworkPolicy || expectedPolicies[ownerNamespace][ownerName] {
+			return
 		}
+		stalePortGroups.Insert(fmt.Sprintf("%s_%s", ownerNamespace, ownerName))
 	})
 	if err != nil {
 		klog.Errorf("Error in syncing network policies: %v", err)
+		return
+	}
+	for _, portGroupName := range stalePortGroups.List() {
+		klog.Infof("Deleting port group %q of deleted network policy", portGroupName)
+		deletePortGroup(hashedPortGroup(portGroupName))
 	}
 }
 
@@ -783,7 +792,8 @@ func (oc *Controller) deleteNetworkPolicy(policy *knet.NetworkPolicy) {
 func (oc *Controller) handlePeerPodSelectorAddUpdate(gp *gressPolicy, obj interface{}) {
 	pod := obj.(*kapi.Pod)
 	if err := gp.addPeerPod(pod); err != nil {
-		klog.Errorf(err.Error())
+		klog.Errorf("Failed to add peer pod %s/%s to network policy address set: %v",
+			pod.Namespace, pod.Name, err)
 	}
 }
 
@@ -793,7 +803,8 @@ func (oc *Controller) handlePeerPodSelectorAddUpdate(gp *gressPolicy, obj interf
 func (oc *Controller) handlePeerPodSelectorDelete(gp *gressPolicy, obj interface{}) {
 	pod := obj.(*kapi.Pod)
 	if err := gp.deletePeerPod(pod); err != nil {
-		klog.Errorf(err.Error())
+		klog.Errorf("Failed to remove peer pod %s/%s from network policy address set: %v",
+			pod.Namespace, pod.Name, err)
 	}
 }
 
@@ -582,6 +582,47 @@ var _ = Describe("OVN NetworkPolicy Operations", func() {
 			err := app.Run([]string{app.Name})
 			Expect(err).NotTo(HaveOccurred())
 		})
+
+		It("deletes the port group of a deleted networkPolicy", func() {
+			app.Action = func(ctx *cli.Context) error {
+				fakeOvn.start(ctx,
+					&v1.NamespaceList{
+						Items: []v1.Namespace{
+							*newNamespace(namespaceName1),
+						},
+					},
+					&knet.NetworkPolicyList{},
+				)
+
+				staleASNames := []string{
+					getPolicyAddressSetName(namespaceName1, "deleted.policy", knet.PolicyTypeIngress, 0),
+					getPolicyAddressSetName(namespaceName1, "deleted.policy", knet.PolicyTypeEgress, 0),
+				}
+				for _, name := range staleASNames {
+					_, err := fakeOvn.asf.NewAddressSet(name, nil)
+					Expect(err).NotTo(HaveOccurred())
+				}
+				fExec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 --data=bare --no-heading --columns=_uuid find port_group name=" + hashedPortGroup(namespaceName1+"_deleted.policy"),
+					Output: fakeUUID,
+				})
+				fExec.AddFakeCmdsNoOutputNoError([]string{
+					"ovn-nbctl --timeout=15 --if-exists destroy port_group " + fakeUUID,
+				})
+
+				fakeOvn.controller.WatchNetworkPolicy()
+
+				Expect(fExec.CalledMatchesExpected()).To(BeTrue(), fExec.ErrorDesc)
+				// the address sets are left for gcAddressSets
+				for _, name := range staleASNames {
+					fakeOvn.asf.ExpectEmptyAddressSet(getIPv4ASName(name))
+				}
+				return nil
+			}
+
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
 	})
 
 	Context("during execution", func() {
@@ -1486,4 +1527,44 @@ var _ = Describe("OVN NetworkPolicy Low-Level Operations", func() {
 		gp.delNamespaceAddressSet(four, pgName)
 		Expect(fExec.CalledMatchesExpected()).To(BeTrue(), fExec.ErrorDesc)
 	})
+
+	It("round-trips network policy address set names", func() {
+		tests := []struct {
+			namespace  string
+			policyName string
+			policyType knet.PolicyType
+			idx        int
+		}{
+			{"testing", "policy", knet.PolicyTypeIngress, 0},
+			{"testing", "allow.from.monitoring", knet.PolicyTypeEgress, 3},
+			{"testing", "ends.with.egress", knet.PolicyTypeIngress, 12},
+			{"testing", "a..b", knet.PolicyTypeIngress, 1},
+		}
+		for _, tc := range tests {
+			name := getPolicyAddressSetName(tc.namespace, tc.policyName, tc.policyType, tc.idx)
+			namespace, policyName, policyType, idx, err := parsePolicyAddressSetName(name)
+			Expect(err).NotTo(HaveOccurred(), name)
+			Expect(namespace).To(Equal(tc.namespace), name)
+			Expect(policyName).To(Equal(tc.policyName), name)
+			Expect(policyType).To(Equal(tc.policyType), name)
+			Expect(idx).To(Equal(tc.idx), name)
+		}
+	})
+
+	It("refuses to parse ambiguous or malformed network policy address set names", func() {
+		for _, name := range []string{
+			"testing",
+			"testing.policy",
+			"testing.policy.ingress",
+			".policy.ingress.0",
+			"testing..ingress.0",
+			"testing.policy.sideways.0",
+			"testing.policy.ingress.",
+			"testing.policy.ingress.-1",
+			"testing.policy.ingress.zero",
+		} {
+			_, _, _, _, err := parsePolicyAddressSetName(name)
+			Expect(err).To(HaveOccurred(), name)
+		}
+	})
 })
@@ -71,7 +71,7 @@ func (ovn *Controller) syncServices(services []interface{}) {
 
 	// Get OVN's current cluster load balancer VIPs and delete them if they
 	// are stale.
-	for _, protocol := range []kapi.Protocol{kapi.ProtocolTCP, kapi.ProtocolUDP, kapi.ProtocolSCTP} {
+	for _, protocol := range ovn.getLoadBalancerProtocols() {
 		loadBalancer, err := ovn.getLoadBalancer(protocol)
 		if err != nil {
 			klog.Errorf("Failed to get load balancer for %s (%v)", kapi.Protocol(protocol), err)
@@ -102,7 +102,7 @@ func (ovn *Controller) syncServices(services []interface{}) {
 	}
 
 	for _, gateway := range gateways {
-		for _, protocol := range []kapi.Protocol{kapi.ProtocolTCP, kapi.ProtocolUDP, kapi.ProtocolSCTP} {
+		for _, protocol := range ovn.getLoadBalancerProtocols() {
 			loadBalancer, err := ovn.getGatewayLoadBalancer(gateway, protocol)
 			if err != nil {
 				klog.Errorf("Gateway router %s does not have load balancer (%v)", gateway, err)
@@ -307,6 +307,10 @@ func (ovn *Controller) deleteService(service *kapi.Service) {
 			klog.Errorf("Skipping delete for service port %s: %v", svcPort.Name, err)
 			continue
 		}
+		if !ovn.SCTPSupport && svcPort.Protocol == kapi.ProtocolSCTP {
+			// nothing was created for it
+			continue
+		}
 
 		if util.ServiceTypeHasNodePort(service) {
 			// Delete the 'NodePort' service from a load balancer instantiated in gateways.
@@ -15,10 +15,16 @@ type SubnetAllocator struct {
 
 	v4ranges []*subnetAllocatorRange
 	v6ranges []*subnetAllocatorRange
+
+	// keyedNetworks maps each key passed to AllocateNetworkForKey or
+	// AllocateOrReuse to the networks allocated for it
+	keyedNetworks map[string][]*net.IPNet
 }
 
 func NewSubnetAllocator() *SubnetAllocator {
-	return &SubnetAllocator{}
+	return &SubnetAllocator{
+		keyedNetworks: make(map[string][]*net.IPNet),
+	}
 }
 
 func (sna *SubnetAllocator) AddNetworkRange(network *net.IPNet, hostSubnetLen int) error {
@@ -73,6 +79,10 @@ func (sna *SubnetAllocator) AllocateNetworks() ([]*net.IPNet, error) {
 	sna.Lock()
 	defer sna.Unlock()
 
+	return sna.allocateNetworks()
+}
+
+func (sna *SubnetAllocator) allocateNetworks() ([]*net.IPNet, error) {
 	var networks []*net.IPNet
 	var err error
 	networks, err = maybeAllocateOneNetwork(sna.v4ranges, networks)
@@ -86,23 +96,136 @@ func (sna *SubnetAllocator) AllocateNetworks() ([]*net.IPNet, error) {
 	return networks, nil
 }
 
+// AllocateNetworkForKey allocates the given subnet for key, so that later
+// calls to AllocateOrReuse for the same key return it. It returns an error if
+// the subnet is not part of any range or is already allocated, unless it was
+// allocated for the same key.
+func (sna *SubnetAllocator) AllocateNetworkForKey(key string, subnet *net.IPNet) error {
+	sna.Lock()
+	defer sna.Unlock()
+
+	str := subnet.String()
+	for otherKey, networks := range sna.keyedNetworks {
+		for _, network := range networks {
+			if network.String() != str {
+				continue
+			}
+			if otherKey == key {
+				return nil
+			}
+			return fmt.Errorf("network %s is already allocated for %q", str, otherKey)
+		}
+	}
+
+	snr := sna.findRange(subnet)
+	if snr == nil {
+		return fmt.Errorf("network %s does not belong to any known range", str)
+	}
+	if snr.allocMap[str] {
+		return fmt.Errorf("network %s is already allocated", str)
+	}
+	snr.allocMap[str] = true
+	sna.keyedNetworks[key] = append(sna.keyedNetworks[key], subnet)
+	return nil
+}
+
+// findRange returns the range that subnet is part of, or nil
+func (sna *SubnetAllocator) findRange(subnet *net.IPNet) *subnetAllocatorRange {
+	for _, snr := range sna.v4ranges {
+		if snr.network.Contains(subnet.IP) {
+			return snr
+		}
+	}
+	for _, snr := range sna.v6ranges {
+		if snr.network.Contains(subnet.IP) {
+			return snr
+		}
+	}
+	return nil
+}
+
+// AllocateOrReuse returns the networks previously allocated for key by
+// AllocateNetworkForKey or AllocateOrReuse, if they haven't been released
+// since. Otherwise it allocates new networks, as AllocateNetworks does, and
+// remembers them for key.
+func (sna *SubnetAllocator) AllocateOrReuse(key string) ([]*net.IPNet, error) {
+	sna.Lock()
+	defer sna.Unlock()
+
+	if networks := sna.keyedNetworks[key]; len(networks) > 0 {
+		return append([]*net.IPNet{}, networks...), nil
+	}
+
+	networks, err := sna.allocateNetworks()
+	if err != nil {
+		return nil, err
+	}
+	sna.keyedNetworks[key] = append([]*net.IPNet{}, networks...)
+	return networks, nil
+}
+
 func (sna *SubnetAllocator) ReleaseNetwork(subnet *net.IPNet) error {
 	sna.Lock()
 	defer sna.Unlock()
 
 	for _, snr := range sna.v4ranges {
 		if snr.releaseNetwork(subnet) {
+			sna.forgetKeyedNetwork(subnet)
 			return nil
 		}
 	}
 	for _, snr := range sna.v6ranges {
 		if snr.releaseNetwork(subnet) {
+			sna.forgetKeyedNetwork(subnet)
 			return nil
 		}
 	}
 	return fmt.Errorf("network %s does not belong to any known range", subnet.String())
 }
 
+// forgetKeyedNetwork removes a released network from the networks of its key
+func (sna *SubnetAllocator) forgetKeyedNetwork(subnet *net.IPNet) {
+	str := subnet.String()
+	for key, networks := range sna.keyedNetworks {
+		for i, network := range networks {
+			if network.String() != str {
+				continue
+			}
+			networks = append(networks[:i], networks[i+1:]...)
+			if len(networks) == 0 {
+				delete(sna.keyedNetworks, key)
+			} else {
+				sna.keyedNetworks[key] = networks
+			}
+			return
+		}
+	}
+}
+
+// RangeUsage describes how many of the subnets in one network range of a
+// SubnetAllocator are currently allocated
+type RangeUsage struct {
+	CIDR      string
+	Total     uint64
+	Allocated uint64
+}
+
+// Usage returns the total and allocated subnet counts of each network range,
+// IPv4 ranges first
+func (sna *SubnetAllocator) Usage() []RangeUsage {
+	sna.Lock()
+	defer sna.Unlock()
+
+	usage := make([]RangeUsage, 0, len(sna.v4ranges)+len(sna.v6ranges))
+	for _, snr := range sna.v4ranges {
+		usage = append(usage, snr.usage())
+	}
+	for _, snr := range sna.v6ranges {
+		usage = append(usage, snr.usage())
+	}
+	return usage
+}
+
 // subnetAllocatorRange handles allocating subnets out of a single CIDR
 type subnetAllocatorRange struct {
 	network    *net.IPNet
@@ -177,18 +300,39 @@ func (snr *subnetAllocatorRange) markAllocatedNetwork(network *net.IPNet) bool {
 	return snr.allocMap[str]
 }
 
+// numSubnets returns the number of subnets that may be allocated from snr
+func (snr *subnetAllocatorRange) numSubnets() uint32 {
+	if snr.subnetBits > 24 {
+		// We need to make sure that the uint32 math in allocateNetwork won't
+		// overflow. If snr.subnetBits > 32 then numSubnets would have already
+		// overflowed, but also if numSubnets is between 1<<24 and 1<<32 then
+		// "base << (snr.hostBits % 8)" could overflow if snr.hostBits%8 is
+		// non-0. So we cap numSubnets at 1<<24. "16M subnets ought to be
+		// enough for anybody."
+		return 1 << 24
+	}
+	return uint32(1) << snr.subnetBits
+}
+
+// usage returns the total and allocated subnet counts of snr
+func (snr *subnetAllocatorRange) usage() RangeUsage {
+	var allocated uint64
+	for _, inUse := range snr.allocMap {
+		if inUse {
+			allocated++
+		}
+	}
+	return RangeUsage{
+		CIDR:      snr.network.String(),
+		Total:     uint64(snr.numSubnets()),
+		Allocated: allocated,
+	}
+}
+
 // allocateNetwork returns a new subnet, or nil if the range is full
 func (snr *subnetAllocatorRange) allocateNetwork() *net.IPNet {
 	netMaskSize, addrLen := snr.network.Mask.Size()
-	numSubnets := uint32(1) << snr.subnetBits
-	if snr.subnetBits > 24 {
-		// We need to make sure that the uint32 math below won't overflow. If
-		// snr.subnetBits > 32 then numSubnets has already overflowed, but also if
-		// numSubnets is between 1<<24 and 1<<32 then "base << (snr.hostBits % 8)"
-		// below could overflow if snr.hostBits%8 is non-0. So we cap numSubnets
-		// at 1<<24. "16M subnets ought to be enough for anybody."
-		numSubnets = 1 << 24
-	}
+	numSubnets := snr.numSubnets()
 
 	var i uint32
 	for i = 0; i < numSubnets; i++ {
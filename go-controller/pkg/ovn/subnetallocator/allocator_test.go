@@ -481,3 +481,141 @@ func TestDualStack(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestUsage(t *testing.T) {
+	sna := NewSubnetAllocator()
+	if err := sna.AddNetworkRange(ovntest.MustParseIPNet("10.1.0.0/16"), 18); err != nil {
+		t.Fatal("Failed to add network range: ", err)
+	}
+	if err := sna.AddNetworkRange(ovntest.MustParseIPNet("fd01::/48"), 64); err != nil {
+		t.Fatal("Failed to add network range: ", err)
+	}
+
+	checkUsage := func(allocatedV4, allocatedV6 uint64) {
+		expected := []RangeUsage{
+			{CIDR: "10.1.0.0/16", Total: 4, Allocated: allocatedV4},
+			{CIDR: "fd01::/48", Total: 65536, Allocated: allocatedV6},
+		}
+		usage := sna.Usage()
+		if len(usage) != len(expected) {
+			t.Fatalf("Expected %d ranges, got %d: %v", len(expected), len(usage), usage)
+		}
+		for i := range expected {
+			if usage[i] != expected[i] {
+				t.Fatalf("Expected usage %v, got %v", expected[i], usage[i])
+			}
+		}
+	}
+
+	checkUsage(0, 0)
+
+	sns, err := sna.AllocateNetworks()
+	if err != nil {
+		t.Fatal("Failed to allocate networks: ", err)
+	}
+	if err := sna.MarkAllocatedNetwork(ovntest.MustParseIPNet("10.1.128.0/18")); err != nil {
+		t.Fatal("Failed to mark network as allocated: ", err)
+	}
+	checkUsage(2, 1)
+
+	for _, sn := range sns {
+		if err := sna.ReleaseNetwork(sn); err != nil {
+			t.Fatalf("Failed to release the subnet (sn=%s): %v", sn, err)
+		}
+	}
+	checkUsage(1, 0)
+}
+
+func TestAllocateOrReuse(t *testing.T) {
+	sna, err := newSubnetAllocator("10.1.0.0/16", 18)
+	if err != nil {
+		t.Fatal("Failed to initialize subnet allocator: ", err)
+	}
+
+	sns, err := sna.AllocateOrReuse("node1")
+	if err != nil {
+		t.Fatal("Failed to allocate network: ", err)
+	}
+	if len(sns) != 1 || sns[0].String() != "10.1.0.0/18" {
+		t.Fatalf("Did not get expected subnet (sns=%v)", sns)
+	}
+	if err := allocateExpected(sna, 1, "10.1.64.0/18"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same key gets the same network back, without allocating another
+	sns, err = sna.AllocateOrReuse("node1")
+	if err != nil {
+		t.Fatal("Failed to reuse network: ", err)
+	}
+	if len(sns) != 1 || sns[0].String() != "10.1.0.0/18" {
+		t.Fatalf("Did not get the reused subnet (sns=%v)", sns)
+	}
+	if err := allocateExpected(sna, 2, "10.1.128.0/18"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Once released, the network is no longer reused for the key
+	if err := sna.ReleaseNetwork(ovntest.MustParseIPNet("10.1.0.0/18")); err != nil {
+		t.Fatal("Failed to release network: ", err)
+	}
+	if err := allocateExpected(sna, 3, "10.1.192.0/18"); err != nil {
+		t.Fatal(err)
+	}
+	sns, err = sna.AllocateOrReuse("node1")
+	if err != nil {
+		t.Fatal("Failed to allocate network: ", err)
+	}
+	if len(sns) != 1 || sns[0].String() != "10.1.0.0/18" {
+		t.Fatalf("Did not get expected subnet (sns=%v)", sns)
+	}
+	if sns, err := sna.AllocateOrReuse("node2"); err != ErrSubnetAllocatorFull {
+		t.Fatalf("Unexpectedly succeeded in allocating network (sns=%v, err=%v)", sns, err)
+	}
+}
+
+func TestAllocateNetworkForKey(t *testing.T) {
+	sna, err := newSubnetAllocator("10.1.0.0/16", 18)
+	if err != nil {
+		t.Fatal("Failed to initialize subnet allocator: ", err)
+	}
+
+	subnet := ovntest.MustParseIPNet("10.1.128.0/18")
+	if err := sna.AllocateNetworkForKey("node1", subnet); err != nil {
+		t.Fatal("Failed to allocate network for key: ", err)
+	}
+	// Allocating it again for the same key is a no-op
+	if err := sna.AllocateNetworkForKey("node1", subnet); err != nil {
+		t.Fatal("Failed to allocate network for key again: ", err)
+	}
+	if err := sna.AllocateNetworkForKey("node2", subnet); err == nil {
+		t.Fatalf("Unexpectedly succeeded in allocating network %s for another key", subnet)
+	}
+
+	sns, err := sna.AllocateOrReuse("node1")
+	if err != nil {
+		t.Fatal("Failed to reuse network: ", err)
+	}
+	if len(sns) != 1 || sns[0].String() != subnet.String() {
+		t.Fatalf("Did not get the reserved subnet (sns=%v)", sns)
+	}
+
+	// The reserved network is skipped by other allocations
+	if err := allocateExpected(sna, 1, "10.1.0.0/18"); err != nil {
+		t.Fatal(err)
+	}
+	if err := allocateExpected(sna, 2, "10.1.64.0/18"); err != nil {
+		t.Fatal(err)
+	}
+	if err := allocateExpected(sna, 3, "10.1.192.0/18"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Networks allocated without a key can't be claimed for one
+	if err := sna.AllocateNetworkForKey("node2", ovntest.MustParseIPNet("10.1.0.0/18")); err == nil {
+		t.Fatal("Unexpectedly succeeded in allocating an already allocated network for a key")
+	}
+	if err := sna.AllocateNetworkForKey("node2", ovntest.MustParseIPNet("10.2.0.0/18")); err == nil {
+		t.Fatal("Unexpectedly succeeded in allocating a network that doesn't belong to any range")
+	}
+}
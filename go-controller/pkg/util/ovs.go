@@ -16,6 +16,7 @@ import (
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
 	"github.com/prometheus/client_golang/prometheus"
 
+	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/klog"
 	kexec "k8s.io/utils/exec"
 )
@@ -477,6 +478,49 @@ func RunOVNNbctl(args ...string) (string, string, error) {
 	return RunOVNNbctlWithTimeout(ovsCommandTimeout, args...)
 }
 
+// OVNNbctlRetryBackoff is the policy RunOVNNbctlWithRetry uses to retry
+// ovn-nbctl commands that fail transiently
+var OVNNbctlRetryBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 500 * time.Millisecond,
+	Factor:   2.0,
+	Jitter:   0.1,
+}
+
+// transientNbctlErrors are ovn-nbctl error messages indicating that the NB
+// database was temporarily unreachable (eg, while a new raft leader is being
+// elected), rather than that the command itself was wrong
+var transientNbctlErrors = []string{
+	"Connection refused",
+	"Connection reset by peer",
+	"database connection failed",
+	"not leader",
+}
+
+func isTransientNbctlError(stderr string) bool {
+	for _, msg := range transientNbctlErrors {
+		if strings.Contains(stderr, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// RunOVNNbctlWithRetry runs a command via ovn-nbctl like RunOVNNbctl, but if
+// the command fails because the NB database is transiently unavailable it
+// retries it, backing off according to OVNNbctlRetryBackoff.
+func RunOVNNbctlWithRetry(args ...string) (string, string, error) {
+	backoff := OVNNbctlRetryBackoff
+	for {
+		stdout, stderr, err := RunOVNNbctl(args...)
+		if err == nil || !isTransientNbctlError(stderr) || backoff.Steps <= 1 {
+			return stdout, stderr, err
+		}
+		klog.V(5).Infof("Transient ovn-nbctl failure, retrying: %v (%s)", err, strings.TrimSpace(stderr))
+		time.Sleep(backoff.Step())
+	}
+}
+
 // RunOVNSbctlUnix runs command via ovn-sbctl, with ovn-sbctl using the unix
 // domain sockets to connect to the ovsdb-server backing the OVN NB database.
 func RunOVNSbctlUnix(args ...string) (string, string, error) {
@@ -1,11 +1,14 @@
 package util
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/urfave/cli/v2"
+	"k8s.io/apimachinery/pkg/util/wait"
 
 	"github.com/ovn-org/ovn-kubernetes/go-controller/pkg/config"
 	ovntest "github.com/ovn-org/ovn-kubernetes/go-controller/pkg/testing"
@@ -177,4 +180,91 @@ var _ = Describe("Util OVS/OVN command tests", func() {
 			Expect(err).NotTo(HaveOccurred())
 		})
 	})
+
+	Context("when running ovn-nbctl with retries", func() {
+		var savedBackoff wait.Backoff
+
+		BeforeEach(func() {
+			// the unit tests in this package replace the runner with mocks
+			runCmdExecRunner = &defaultExecRunner{}
+			savedBackoff = OVNNbctlRetryBackoff
+			OVNNbctlRetryBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond, Factor: 1.0}
+		})
+
+		AfterEach(func() {
+			OVNNbctlRetryBackoff = savedBackoff
+		})
+
+		It("retries a command that fails transiently until it succeeds", func() {
+			app.Action = func(ctx *cli.Context) error {
+				for i := 0; i < 2; i++ {
+					fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+						Cmd:    "ovn-nbctl --timeout=15 get logical_router GR_node1 name",
+						Stderr: "ovn-nbctl: transaction error: {\"details\":\"...\",\"error\":\"not leader\"}",
+						Err:    fmt.Errorf("exit status 1"),
+					})
+				}
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 get logical_router GR_node1 name",
+					Output: "GR_node1",
+				})
+				err := SetExec(fexec)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = config.InitConfig(ctx, fexec, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				stdout, _, err := RunOVNNbctlWithRetry("get", "logical_router", "GR_node1", "name")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(stdout).To(Equal("GR_node1"))
+				Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+				return nil
+			}
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("gives up once the retries are used up", func() {
+			app.Action = func(ctx *cli.Context) error {
+				for i := 0; i < 3; i++ {
+					fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+						Cmd:    "ovn-nbctl --timeout=15 get logical_router GR_node1 name",
+						Stderr: "ovn-nbctl: unix:/var/run/ovn/ovnnb_db.sock: database connection failed (End of file)",
+						Err:    fmt.Errorf("exit status 1"),
+					})
+				}
+				err := SetExec(fexec)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = config.InitConfig(ctx, fexec, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, err = RunOVNNbctlWithRetry("get", "logical_router", "GR_node1", "name")
+				Expect(err).To(HaveOccurred())
+				Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+				return nil
+			}
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("does not retry a command that fails for other reasons", func() {
+			app.Action = func(ctx *cli.Context) error {
+				fexec.AddFakeCmd(&ovntest.ExpectedCmd{
+					Cmd:    "ovn-nbctl --timeout=15 get logical_router GR_node1 name",
+					Stderr: "ovn-nbctl: no row \"GR_node1\" in table Logical_Router",
+					Err:    fmt.Errorf("exit status 1"),
+				})
+				err := SetExec(fexec)
+				Expect(err).NotTo(HaveOccurred())
+				_, err = config.InitConfig(ctx, fexec, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, err = RunOVNNbctlWithRetry("get", "logical_router", "GR_node1", "name")
+				Expect(err).To(HaveOccurred())
+				Expect(fexec.CalledMatchesExpected()).To(BeTrue(), fexec.ErrorDesc)
+				return nil
+			}
+			err := app.Run([]string{app.Name})
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
 })
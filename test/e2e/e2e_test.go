@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
@@ -19,14 +20,27 @@ import (
 	"k8s.io/kubernetes/test/e2e/framework"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	utilexec "k8s.io/client-go/util/exec"
 	e2epod "k8s.io/kubernetes/test/e2e/framework/pod"
 )
 
 const (
 	// IANA assigned VXLAN UDP port - rfc7348
-	vxlanPort            = "4789"
-	podNetworkAnnotation = "k8s.ovn.org/pod-networks"
-	exGwAnnotation       = "k8s.ovn.org/hybrid-overlay-external-gw"
+	vxlanPort             = "4789"
+	podNetworkAnnotation  = "k8s.ovn.org/pod-networks"
+	exGwAnnotation        = "k8s.ovn.org/hybrid-overlay-external-gw"
+	vtepAnnotation        = "k8s.ovn.org/hybrid-overlay-vtep"
+	routingExGwAnnotation = "k8s.ovn.org/routing-external-gws"
+
+	// gatewayContainerImageEnv names the environment variable that overrides
+	// the image used for external gateway containers. The image must run a
+	// shell by default, so that the detached container keeps running, and
+	// provide the ip command from iproute. gatewayContainerImageLegacyEnv is
+	// still accepted when gatewayContainerImageEnv is unset.
+	gatewayContainerImageEnv       = "OVN_TEST_EX_GW_BFD_IMAGE"
+	gatewayContainerImageLegacyEnv = "OVN_TEST_EX_GW_IMAGE"
+	defaultGatewayContainerImage   = "centos"
 )
 
 func checkContinuousConnectivity(f *framework.Framework, nodeName, podName, host string, port, timeout int, podChan chan *v1.Pod, errChan chan error) {
@@ -216,6 +230,28 @@ func getPodAddress(podName, namespace string) (string, error) {
 	return podIP, nil
 }
 
+// execPodCommand runs cmd in the given container of a pod and returns its
+// stdout and exit code. err is only set if the command could not be run at
+// all; a command that ran and failed returns a non-zero exit code instead.
+func execPodCommand(f *framework.Framework, namespace, podName, containerName string, cmd []string) (string, int, error) {
+	stdout, stderr, err := f.ExecWithOptions(framework.ExecOptions{
+		Command:       cmd,
+		Namespace:     namespace,
+		PodName:       podName,
+		ContainerName: containerName,
+		CaptureStdout: true,
+		CaptureStderr: true,
+	})
+	if err != nil {
+		if exitErr, ok := err.(utilexec.ExitError); ok {
+			framework.Logf("%q in pod %s/%s exited with %d: %s", strings.Join(cmd, " "), namespace, podName, exitErr.ExitStatus(), stderr)
+			return stdout, exitErr.ExitStatus(), nil
+		}
+		return stdout, -1, fmt.Errorf("failed to exec %q in pod %s/%s: %v (%s)", strings.Join(cmd, " "), namespace, podName, err, stderr)
+	}
+	return stdout, 0, nil
+}
+
 // runCommand runs the cmd and returns the combined stdout and stderr
 func runCommand(cmd ...string) (string, error) {
 	output, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput()
@@ -225,6 +261,338 @@ func runCommand(cmd ...string) (string, error) {
 	return string(output), nil
 }
 
+// gatewayContainerImage returns the image to run external gateway containers
+// from: the one named by $OVN_TEST_EX_GW_BFD_IMAGE or $OVN_TEST_EX_GW_IMAGE,
+// or centos by default
+func gatewayContainerImage() string {
+	for _, env := range []string{gatewayContainerImageEnv, gatewayContainerImageLegacyEnv} {
+		if image := os.Getenv(env); image != "" {
+			return image
+		}
+	}
+	return defaultGatewayContainerImage
+}
+
+// ensureGatewayContainerImage pulls the external gateway container image if it
+// isn't present yet, so that a missing image is reported as such rather than
+// as a failure of whatever gateway setup step happens to run first
+func ensureGatewayContainerImage(image string) error {
+	if _, err := runCommand("docker", "image", "inspect", image); err == nil {
+		return nil
+	}
+	if _, err := runCommand("docker", "pull", image); err != nil {
+		return fmt.Errorf("external gateway image %q is not available; set %s to an image running a shell and providing iproute: %v",
+			image, gatewayContainerImageEnv, err)
+	}
+	return nil
+}
+
+// startGatewayContainer starts a privileged docker container that acts as an
+// external gateway, passing extraArgs to "docker run". A container left behind
+// with the same name, eg by an earlier run whose cleanup didn't happen, is
+// removed first rather than making "docker run" fail.
+func startGatewayContainer(containerName string, extraArgs ...string) error {
+	image := gatewayContainerImage()
+	if err := ensureGatewayContainerImage(image); err != nil {
+		return err
+	}
+	cid, err := runCommand("docker", "ps", "-qaf", fmt.Sprintf("name=^%s$", containerName))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(cid) != "" {
+		framework.Logf("Removing stale container %s", containerName)
+		if _, err := runCommand("docker", "rm", "-f", containerName); err != nil {
+			return err
+		}
+	}
+	args := append([]string{"docker", "run", "-itd", "--privileged"}, extraArgs...)
+	args = append(args, "--name", containerName, image)
+	_, err = runCommand(args...)
+	return err
+}
+
+// addContainerRoute adds a route to dst, an IP address or CIDR, in the given
+// docker container, using "ip -6 route" for IPv6 destinations. routeArgs are
+// appended to the route, eg "dev", "vxlan0" or "via", nextHop.
+func addContainerRoute(containerName, dst string, routeArgs ...string) error {
+	ipCmd := []string{"docker", "exec", containerName, "ip"}
+	if strings.Contains(dst, ":") {
+		ipCmd = append(ipCmd, "-6")
+	}
+	ipCmd = append(ipCmd, "route", "add", dst)
+	_, err := runCommand(append(ipCmd, routeArgs...)...)
+	return err
+}
+
+// setNamespaceExternalGateway annotates the namespace so that its pods use
+// gateway as their hybrid overlay external gateway, reached through the vxlan
+// endpoint vtep. Any existing gateway annotations are overwritten. gateway may
+// be a comma-separated list; each one must be of an IP family that the
+// cluster uses, since others would be silently ignored.
+func setNamespaceExternalGateway(namespace, gateway, vtep string) error {
+	if err := validateGatewayIPs(strings.Split(gateway, ",")); err != nil {
+		return err
+	}
+	_, err := framework.RunKubectl("annotate", "namespace", namespace,
+		fmt.Sprintf("%s=%s", exGwAnnotation, gateway),
+		fmt.Sprintf("%s=%s", vtepAnnotation, vtep),
+		"--overwrite")
+	return err
+}
+
+// validateGatewayIPs returns an error if any of the gateways is not a valid IP
+// address of an IP family that the cluster's pod subnets use
+func validateGatewayIPs(gateways []string) error {
+	nodeName, err := framework.RunKubectl("get", "nodes", "-o", "jsonpath='{.items[0].metadata.name}'")
+	if err != nil {
+		return fmt.Errorf("failed to get a node name: %v", err)
+	}
+	nodeName = strings.Trim(nodeName, "'")
+	cidrs, err := getNodePodCIDRs(nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get the pod subnets of node %s: %v", nodeName, err)
+	}
+	for _, gateway := range gateways {
+		ip := net.ParseIP(strings.TrimSpace(gateway))
+		if ip == nil {
+			return fmt.Errorf("gateway %q is not a valid IP address", gateway)
+		}
+		familyEnabled := false
+		for _, cidr := range cidrs {
+			if (ip.To4() != nil) == (cidr.IP.To4() != nil) {
+				familyEnabled = true
+				break
+			}
+		}
+		if !familyEnabled {
+			return fmt.Errorf("gateway %s is of an IP family the cluster doesn't use (pod subnets %v)", gateway, cidrs)
+		}
+	}
+	return nil
+}
+
+// waitForPodExternalGateway waits until the given pod in f's namespace carries
+// the hybrid overlay external gateway and vtep annotations copied from its
+// namespace
+func waitForPodExternalGateway(f *framework.Framework, podName, gateway, vtep string) error {
+	podClient := f.ClientSet.CoreV1().Pods(f.Namespace.Name)
+	var annotations map[string]string
+	err := wait.PollImmediate(2*time.Second, 60*time.Second, func() (bool, error) {
+		pod, err := podClient.Get(podName, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		annotations = pod.Annotations
+		return annotations[exGwAnnotation] == gateway && annotations[vtepAnnotation] == vtep, nil
+	})
+	if err != nil {
+		return fmt.Errorf("pod %s did not get external gateway %s and vtep %s (annotations: %v): %v",
+			podName, gateway, vtep, annotations, err)
+	}
+	return nil
+}
+
+// restartOVNKubeNode deletes the ovnkube-node pod running on nodeName in the
+// given namespace and waits for its replacement to become ready
+func restartOVNKubeNode(f *framework.Framework, namespace, nodeName string) error {
+	return restartPods(f, namespace, metav1.ListOptions{
+		LabelSelector: "name=ovnkube-node",
+		FieldSelector: "spec.nodeName=" + nodeName,
+	})
+}
+
+// restartOVNKubeMaster deletes the ovnkube-master pods in the given namespace
+// and waits for all of their replacements to become ready
+func restartOVNKubeMaster(f *framework.Framework, namespace string) error {
+	return restartPods(f, namespace, metav1.ListOptions{
+		LabelSelector: "name=ovnkube-master",
+	})
+}
+
+// restartPods deletes the pods selected by listOptions and waits until the
+// same number of new pods are ready
+func restartPods(f *framework.Framework, namespace string, listOptions metav1.ListOptions) error {
+	podClient := f.ClientSet.CoreV1().Pods(namespace)
+	pods, err := podClient.List(listOptions)
+	if err != nil {
+		return fmt.Errorf("failed to list pods with %s %s: %v", listOptions.LabelSelector, listOptions.FieldSelector, err)
+	}
+	if len(pods.Items) == 0 {
+		return fmt.Errorf("no pods with %s %s found", listOptions.LabelSelector, listOptions.FieldSelector)
+	}
+	oldPods := make(map[types.UID]bool)
+	for _, pod := range pods.Items {
+		oldPods[pod.UID] = true
+		if err := podClient.Delete(pod.Name, &metav1.DeleteOptions{}); err != nil {
+			return fmt.Errorf("failed to delete pod %s: %v", pod.Name, err)
+		}
+	}
+
+	return wait.PollImmediate(2*time.Second, 2*time.Minute, func() (bool, error) {
+		pods, err := podClient.List(listOptions)
+		if err != nil {
+			return false, nil
+		}
+		ready := 0
+		for _, pod := range pods.Items {
+			if oldPods[pod.UID] || pod.DeletionTimestamp != nil {
+				continue
+			}
+			for _, cond := range pod.Status.Conditions {
+				if cond.Type == v1.PodReady && cond.Status == v1.ConditionTrue {
+					ready++
+				}
+			}
+		}
+		return ready == len(oldPods), nil
+	})
+}
+
+// staticRoute is a logical router static route as listed by ovn-nbctl
+type staticRoute struct {
+	prefix  string
+	nexthop string
+	policy  string
+	ecmp    bool
+}
+
+// getLogicalRouterStaticRoutes returns the static routes of the gateway
+// router of the given node, as listed by ovn-nbctl in the ovnkube-db pod in
+// the given namespace
+func getLogicalRouterStaticRoutes(f *framework.Framework, namespace, nodeName string) ([]staticRoute, error) {
+	pods, err := f.ClientSet.CoreV1().Pods(namespace).List(metav1.ListOptions{
+		LabelSelector: "name=ovnkube-db",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ovnkube-db pods: %v", err)
+	}
+	if len(pods.Items) == 0 {
+		return nil, fmt.Errorf("no ovnkube-db pods found in namespace %s", namespace)
+	}
+	gatewayRouter := "GR_" + nodeName
+	out, exitCode, err := execPodCommand(f, namespace, pods.Items[0].Name, "nb-ovsdb",
+		[]string{"ovn-nbctl", "--no-leader-only", "lr-route-list", gatewayRouter})
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("failed to list the static routes of %s: exit code %d", gatewayRouter, exitCode)
+	}
+
+	var routes []staticRoute
+	for _, line := range strings.Split(out, "\n") {
+		// e.g. "10.244.1.3    172.18.0.5 src-ip rtoe-GR_ovn-worker ecmp";
+		// the "IPv4 Routes" and "IPv6 Routes" headers are skipped
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] == "Routes" {
+			continue
+		}
+		route := staticRoute{prefix: fields[0], nexthop: fields[1], policy: fields[2]}
+		for _, field := range fields[3:] {
+			if field == "ecmp" {
+				route.ecmp = true
+			}
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// waitForPodExternalRoutes waits until the gateway router of nodeName has an
+// src-ip route for podIP via each of the gateways, marked ecmp if there are
+// several of them
+func waitForPodExternalRoutes(f *framework.Framework, namespace, nodeName, podIP string, gateways []string) error {
+	var routes []staticRoute
+	err := wait.PollImmediate(2*time.Second, 60*time.Second, func() (bool, error) {
+		var err error
+		routes, err = getLogicalRouterStaticRoutes(f, namespace, nodeName)
+		if err != nil {
+			framework.Logf("failed to get the static routes of node %s: %v", nodeName, err)
+			return false, nil
+		}
+		for _, gateway := range gateways {
+			found := false
+			for _, route := range routes {
+				// host routes are listed without their /32 or /128 mask
+				prefix := strings.Split(route.prefix, "/")[0]
+				if prefix == podIP && route.nexthop == gateway && route.policy == "src-ip" &&
+					(len(gateways) == 1 || route.ecmp) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("GR_%s did not get routes for pod %s via %v (routes: %+v): %v",
+			nodeName, podIP, gateways, routes, err)
+	}
+	return nil
+}
+
+// skipIfIPv6OnlyKindNetwork skips the current test if containerName has only
+// an IPv6 address on the kind network. The hybrid overlay vxlan external
+// gateways don't support IPv6, and a missing IPv4 address must not be mistaken
+// for a kind v7 or earlier install using the default "bridge" network.
+func skipIfIPv6OnlyKindNetwork(containerName string) {
+	ipv6, err := runCommand("docker", "inspect", "-f", "{{ .NetworkSettings.Networks.kind.GlobalIPv6Address }}", containerName)
+	if err != nil {
+		return
+	}
+	if ip := net.ParseIP(strings.TrimSpace(ipv6)); ip != nil {
+		framework.Skipf("Hybrid overlay external gateways are not supported on IPv6-only clusters")
+	}
+}
+
+// getContainerAddresses returns all of the IP addresses, on all interfaces,
+// of the given docker container
+func getContainerAddresses(containerName string) ([]net.IP, error) {
+	out, err := runCommand("docker", "exec", containerName, "ip", "-o", "addr", "show")
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, line := range strings.Split(out, "\n") {
+		// e.g. "1: lo    inet 127.0.0.1/8 scope host lo ..."
+		fields := strings.Fields(line)
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] != "inet" && fields[i] != "inet6" {
+				continue
+			}
+			ip, _, err := net.ParseCIDR(fields[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse address %q of container %s: %v", fields[i+1], containerName, err)
+			}
+			ips = append(ips, ip)
+		}
+	}
+	return ips, nil
+}
+
+// getContainerLogs returns the logs of the given docker container
+func getContainerLogs(containerName string) (string, error) {
+	return runCommand("docker", "logs", containerName)
+}
+
+// dumpContainerLogsOnFailure logs the output of the given docker container if
+// the current test failed. It must be called before the container is deleted.
+func dumpContainerLogsOnFailure(containerName string) {
+	if !ginkgo.CurrentGinkgoTestDescription().Failed {
+		return
+	}
+	logs, err := getContainerLogs(containerName)
+	if err != nil {
+		framework.Logf("failed to get the logs of container %s: %v", containerName, err)
+		return
+	}
+	framework.Logf("Logs of container %s:\n%s", containerName, logs)
+}
+
 var _ = Describe("e2e control plane", func() {
 	var svcname = "nettest"
 
@@ -332,7 +700,7 @@ var _ = Describe("test e2e inter-node connectivity between worker nodes hybrid o
 		fieldSelectorHaFlag := fmt.Sprintf("--field-selector=spec.nodeName=%s", ovnHaWorkerNode2)
 
 		// start the container that will act as an external gateway
-		_, err := runCommand("docker", "run", "-itd", "--privileged", "--name", gwContainerName, "centos")
+		err := startGatewayContainer(gwContainerName)
 		if err != nil {
 			framework.Failf("failed to start external gateway test container: %v", err)
 		}
@@ -344,16 +712,11 @@ var _ = Describe("test e2e inter-node connectivity between worker nodes hybrid o
 		exVtepIP = strings.TrimSuffix(exVtepIP, "\n")
 		framework.Logf("The external gateway IP is %s", exVtepIP)
 
-		annotateArgs := []string{
-			"annotate",
-			"namespace",
-			f.Namespace.Name,
-			fmt.Sprintf("k8s.ovn.org/hybrid-overlay-external-gw=%s", pingTarget),
-			fmt.Sprintf("k8s.ovn.org/hybrid-overlay-vtep=%s", exVtepIP),
-		}
 		// Annotate the pods to route pods to hybrid-sdn bridge br-ext
 		framework.Logf("Annotating the external gateway test namespace")
-		framework.RunKubectlOrDie(annotateArgs...)
+		if err := setNamespaceExternalGateway(f.Namespace.Name, pingTarget, exVtepIP); err != nil {
+			framework.Failf("failed to annotate the external gateway test namespace: %v", err)
+		}
 
 		// Attempt to retrieve the pod name that will run the external interface for e2e control-plane non-ha mode
 		kubectlOut, err := framework.RunKubectl("get", "pods", ovnNsFlag, "-l", labelFlag, jsonFlag, fieldSelectorFlag)
@@ -376,6 +739,7 @@ var _ = Describe("test e2e inter-node connectivity between worker nodes hybrid o
 
 	AfterEach(func() {
 		// tear down the container simulating the gateway
+		dumpContainerLogsOnFailure(gwContainerName)
 		_, err := runCommand("docker", "rm", "-f", gwContainerName)
 		if err != nil {
 			framework.Failf("failed to delete the gateway test container %v", err)
@@ -595,12 +959,13 @@ var _ = Describe("e2e external gateway validation", func() {
 		// trim newline from the inspect output
 		controlNodeIP = strings.TrimSuffix(controlNodeIP, "\n")
 		if ip := net.ParseIP(controlNodeIP); ip == nil {
+			skipIfIPv6OnlyKindNetwork(ovnControlNode)
 			// set values for kind v7 and earlier
 			ciNetworkName = "bridge"
 			ciNetworkFlag = "{{ .NetworkSettings.IPAddress }}"
 		}
 		// start the container that will act as an external gateway
-		_, err = runCommand("docker", "run", "-itd", "--privileged", "--network", ciNetworkName, "--name", gwContainerName, "centos")
+		err = startGatewayContainer(gwContainerName, "--network", ciNetworkName)
 		if err != nil {
 			framework.Failf("failed to start external gateway test container: %v", err)
 		}
@@ -617,16 +982,10 @@ var _ = Describe("e2e external gateway validation", func() {
 		framework.Logf("The external gateway IP is %s", exVtepIP)
 		// annotate the test namespace
 
-		annotateArgs := []string{
-			"annotate",
-			"namespace",
-			f.Namespace.Name,
-			fmt.Sprintf("k8s.ovn.org/hybrid-overlay-external-gw=%s", extGW),
-			fmt.Sprintf("k8s.ovn.org/hybrid-overlay-vtep=%s", exVtepIP),
-		}
-
 		framework.Logf("Annotating the external gateway test namespace")
-		framework.RunKubectlOrDie(annotateArgs...)
+		if err := setNamespaceExternalGateway(f.Namespace.Name, extGW, exVtepIP); err != nil {
+			framework.Failf("failed to annotate the external gateway test namespace: %v", err)
+		}
 		// attempt to retrieve the pod name that will source the tunnel test in non-HA mode
 		kubectlOut, err := framework.RunKubectl("get", "pods", ovnNsFlag, "-l", labelFlag, jsonFlag, fieldSelectorFlag)
 		if err != nil {
@@ -645,13 +1004,14 @@ var _ = Describe("e2e external gateway validation", func() {
 	AfterEach(func() {
 		// tear down the container simulating the gateway
 		if cid, _ := runCommand("docker", "ps", "-qaf", fmt.Sprintf("name=%s",gwContainerName)); cid != "" {
+			dumpContainerLogsOnFailure(gwContainerName)
 			if _, err := runCommand("docker", "rm", "-f", gwContainerName); err != nil {
 				framework.Logf("failed to delete the gateway test container %s %v", gwContainerName, err)
 			}
 		}
 	})
 
-	It("Should validate connectivity to the vxlan interface simulating an external gateway and validate traffic was encapsulated", func() {
+	It("Should validate connectivity to the vxlan interface simulating an external gateway and validate traffic was encapsulated, before and after an ovnkube-node restart", func() {
 		// non-ha ci mode runs a set of kind nodes prefixed with ovn-worker
 		ciWorkerNodeSrc := ovnWorkerNode
 		if haMode {
@@ -686,7 +1046,21 @@ var _ = Describe("e2e external gateway validation", func() {
 		if err != nil {
 			framework.Failf("failed to add the external gateway ip to dev lo on the test container: %v", err)
 		}
-		_, err = runCommand("docker", "exec", gwContainerName, "ip", "route", "add", podCIDR, "dev", "vxlan0")
+		gwAddresses, err := getContainerAddresses(gwContainerName)
+		if err != nil {
+			framework.Failf("failed to get the addresses of the test container: %v", err)
+		}
+		hasExtGW := false
+		for _, ip := range gwAddresses {
+			if ip.Equal(net.ParseIP(extGW)) {
+				hasExtGW = true
+				break
+			}
+		}
+		if !hasExtGW {
+			framework.Failf("the test container does not have the external gateway ip %s, its addresses are %v", extGW, gwAddresses)
+		}
+		err = addContainerRoute(gwContainerName, podCIDR, "dev", "vxlan0")
 		if err != nil {
 			framework.Failf("failed to add the pod route on the test container: %v", err)
 		}
@@ -696,6 +1070,11 @@ var _ = Describe("e2e external gateway validation", func() {
 		framework.ExpectNoError(
 			// generate traffic that will being encapsulated and sent to the external gateway.
 			checkConnectivityPingToHost(f, ciWorkerNodeSrc, "external-gateway-e2e", extGW, ipv4PingCommand, 30, true))
+
+		By(fmt.Sprintf("Restarting ovnkube-node on %s and testing the traffic to the external gateway again", ciWorkerNodeSrc))
+		framework.ExpectNoError(restartOVNKubeNode(f, ovnNs, ciWorkerNodeSrc), "should restart ovnkube-node")
+		framework.ExpectNoError(
+			checkConnectivityPingToHost(f, ciWorkerNodeSrc, "external-gateway-e2e-restart", extGW, ipv4PingCommand, 30, true))
 	})
 })
 
@@ -739,6 +1118,7 @@ var _ = Describe("e2e multiple external gateway update validation", func() {
 		// trim newline from the inspect output
 		controlNodeIP = strings.TrimSuffix(controlNodeIP, "\n")
 		if ip := net.ParseIP(controlNodeIP); ip == nil {
+			skipIfIPv6OnlyKindNetwork(ovnControlNode)
 			// set values for kind v7 and earlier
 			ciNetworkName = "bridge"
 			ciNetworkFlag = "{{ .NetworkSettings.IPAddress }}"
@@ -761,11 +1141,13 @@ var _ = Describe("e2e multiple external gateway update validation", func() {
 	AfterEach(func() {
 		// tear down the containers simulating the gateways
 		if cid, _ := runCommand("docker", "ps", "-qaf", fmt.Sprintf("name=%s",gwContainerNameAlt1)); cid != "" {
+			dumpContainerLogsOnFailure(gwContainerNameAlt1)
 			if _, err := runCommand("docker", "rm", "-f", gwContainerNameAlt1); err != nil {
 				framework.Logf("failed to delete the gateway test container %s %v", gwContainerNameAlt1, err)
 			}
 		}
 		if cid, _ := runCommand("docker", "ps", "-qaf", fmt.Sprintf("name=%s",gwContainerNameAlt2)); cid != "" {
+			dumpContainerLogsOnFailure(gwContainerNameAlt2)
 			if _, err := runCommand("docker", "rm", "-f", gwContainerNameAlt2); err != nil {
 				framework.Logf("failed to delete the gateway test container %s %v", gwContainerNameAlt2, err)
 			}
@@ -780,11 +1162,9 @@ var _ = Describe("e2e multiple external gateway update validation", func() {
 		extGWCidrAlt2 := fmt.Sprintf("%s/24", extGwAlt2)
 		srcPingPodName := "e2e-exgw-src-ping-pod"
 		command := []string{"bash", "-c", "sleep 20000"}
-		frameworkNsFlag := fmt.Sprintf("--namespace=%s", f.Namespace.Name)
 		testContainer := fmt.Sprintf("%s-container", srcPingPodName)
-		testContainerFlag := fmt.Sprintf("--container=%s", testContainer)
 		// start the container that will act as an external gateway
-		_, err := runCommand("docker", "run", "-itd", "--privileged", "--network", ciNetworkName, "--name", gwContainerNameAlt1, "centos")
+		err := startGatewayContainer(gwContainerNameAlt1, "--network", ciNetworkName)
 		if err != nil {
 			framework.Failf("failed to start external gateway test container %s: %v", gwContainerNameAlt1, err)
 		}
@@ -799,15 +1179,10 @@ var _ = Describe("e2e multiple external gateway update validation", func() {
 			framework.Failf("Unable to retrieve a valid address from container %s with inspect output of %s", gwContainerNameAlt1, exVtepIpAlt1)
 		}
 		// annotate the test namespace
-		annotateArgs := []string{
-			"annotate",
-			"namespace",
-			f.Namespace.Name,
-			fmt.Sprintf("k8s.ovn.org/hybrid-overlay-external-gw=%s", extGwAlt1),
-			fmt.Sprintf("k8s.ovn.org/hybrid-overlay-vtep=%s", exVtepIpAlt1),
-		}
 		framework.Logf("Annotating the external gateway test namespace to a new container vtep:%s gw:%s ", exVtepIpAlt1, extGwAlt1)
-		framework.RunKubectlOrDie(annotateArgs...)
+		if err := setNamespaceExternalGateway(f.Namespace.Name, extGwAlt1, exVtepIpAlt1); err != nil {
+			framework.Failf("failed to annotate the external gateway test namespace: %v", err)
+		}
 		// non-ha ci mode runs a set of kind nodes prefixed with ovn-worker
 		ciWorkerNodeSrc := ovnWorkerNode
 		if haMode {
@@ -842,7 +1217,7 @@ var _ = Describe("e2e multiple external gateway update validation", func() {
 		if err != nil {
 			framework.Failf("failed to add the external gateway ip to dev lo on the test container: %v", err)
 		}
-		_, err = runCommand("docker", "exec", gwContainerNameAlt1, "ip", "route", "add", podCIDR, "dev", "vxlan0")
+		err = addContainerRoute(gwContainerNameAlt1, podCIDR, "dev", "vxlan0")
 		if err != nil {
 			framework.Failf("failed to add the pod route on the test container: %v", err)
 		}
@@ -884,12 +1259,15 @@ var _ = Describe("e2e multiple external gateway update validation", func() {
 		time.Sleep(time.Second * 15)
 		// Verify the initial gateway is reachable from the new pod
 		By(fmt.Sprintf("Verifying connectivity to the updated annotation and initial external gateway %s and vtep %s", extGwAlt1, exVtepIpAlt1))
-		_, err = framework.RunKubectl("exec", srcPingPodName, frameworkNsFlag, testContainerFlag, "--", "ping", "-w", "40", extGwAlt1)
+		_, exitCode, err := execPodCommand(f, f.Namespace.Name, srcPingPodName, testContainer, []string{"ping", "-w", "40", extGwAlt1})
 		if err != nil {
-			framework.Failf("Failed to ping the first gateway %s from container %s on node %s: %v", extGwAlt1, ovnContainer, ovnWorkerNode, err)
+			framework.Failf("Failed to run ping to the first gateway %s in container %s on node %s: %v", extGwAlt1, ovnContainer, ovnWorkerNode, err)
+		}
+		if exitCode != 0 {
+			framework.Failf("Failed to ping the first gateway %s from container %s on node %s: exit code %d", extGwAlt1, ovnContainer, ovnWorkerNode, exitCode)
 		}
 		// start the container that will act as a new external gateway that the tests will be updated to use
-		_, err = runCommand("docker", "run", "-itd", "--privileged", "--network", ciNetworkName, "--name", gwContainerNameAlt2, "centos")
+		err = startGatewayContainer(gwContainerNameAlt2, "--network", ciNetworkName)
 		if err != nil {
 			framework.Failf("failed to start external gateway test container %s: %v", gwContainerNameAlt2, err)
 		}
@@ -904,16 +1282,10 @@ var _ = Describe("e2e multiple external gateway update validation", func() {
 			framework.Failf("Unable to retrieve a valid address from container %s with inspect output of %s", gwContainerNameAlt2, localVtepIP)
 		}
 		// override the annotation in the test namespace with the new vtep and gateway
-		annotateArgs = []string{
-			"annotate",
-			"namespace",
-			f.Namespace.Name,
-			fmt.Sprintf("k8s.ovn.org/hybrid-overlay-external-gw=%s", extGwAlt2),
-			fmt.Sprintf("k8s.ovn.org/hybrid-overlay-vtep=%s", exVtepIpAlt2),
-			"--overwrite",
-		}
 		framework.Logf("Annotating the external gateway test namespace to a new container vtep:%s gw:%s ", exVtepIpAlt2, extGwAlt2)
-		framework.RunKubectlOrDie(annotateArgs...)
+		if err := setNamespaceExternalGateway(f.Namespace.Name, extGwAlt2, exVtepIpAlt2); err != nil {
+			framework.Failf("failed to annotate the external gateway test namespace: %v", err)
+		}
 		// setup the new container to emulate a gateway with routes, vtep and a loopback interface acting as the gateway
 		_, err = runCommand("docker", "exec", gwContainerNameAlt2, "ip", "link", "add", "vxlan0", "type", "vxlan", "dev",
 			"eth0", "id", "4097", "dstport", vxlanPort, "remote", localVtepIP)
@@ -928,7 +1300,7 @@ var _ = Describe("e2e multiple external gateway update validation", func() {
 		if err != nil {
 			framework.Failf("failed to add the external gateway ip to dev lo on the test container: %v", err)
 		}
-		_, err = runCommand("docker", "exec", gwContainerNameAlt2, "ip", "route", "add", podCIDR, "dev", "vxlan0")
+		err = addContainerRoute(gwContainerNameAlt2, podCIDR, "dev", "vxlan0")
 		if err != nil {
 			framework.Failf("failed to add the pod route on the test container: %v", err)
 		}
@@ -948,30 +1320,206 @@ var _ = Describe("e2e multiple external gateway update validation", func() {
 
 		// Verify the updated gateway is reachable from the initial pod
 		By(fmt.Sprintf("Verifying connectivity to the updated annotation and new external gateway %s and vtep %s", extGwAlt2, exVtepIpAlt2))
-		_, err = framework.RunKubectl("exec", srcPingPodName, frameworkNsFlag, testContainerFlag, "--", "ping", "-w", "40", extGwAlt2)
+		_, exitCode, err = execPodCommand(f, f.Namespace.Name, srcPingPodName, testContainer, []string{"ping", "-w", "40", extGwAlt2})
 		if err != nil {
-			framework.Failf("Failed to ping the second gateway %s from container %s on node %s: %v", extGwAlt2, ovnContainer, ovnWorkerNode, err)
+			framework.Failf("Failed to run ping to the second gateway %s in container %s on node %s: %v", extGwAlt2, ovnContainer, ovnWorkerNode, err)
+		}
+		if exitCode != 0 {
+			framework.Failf("Failed to ping the second gateway %s from container %s on node %s: exit code %d", extGwAlt2, ovnContainer, ovnWorkerNode, exitCode)
 		}
 	})
 })
 
-func getNodePodCIDR(nodeName string) (string, error) {
+// Test that the hybrid overlay master copies the namespace external gateway
+// annotations to both existing and new pods, and keeps them up to date
+var _ = Describe("e2e hybrid overlay namespace annotation propagation", func() {
+	const (
+		svcname  string = "hybrid-annotations"
+		extGw1   string = "10.249.3.1"
+		extVtep1 string = "172.17.0.251"
+		extGw2   string = "10.249.4.1"
+		extVtep2 string = "172.17.0.252"
+		oldPod   string = "e2e-annotation-old-pod"
+		newPod   string = "e2e-annotation-new-pod"
+	)
+	command := []string{"bash", "-c", "sleep 20000"}
+
+	f := framework.NewDefaultFramework(svcname)
+
+	It("Should copy namespace external gateway annotations to existing and new pods and update them", func() {
+		By("Creating a pod before the namespace is annotated")
+		createGenericPod(f, oldPod, "", command)
+
+		By(fmt.Sprintf("Annotating the namespace with external gateway %s and vtep %s", extGw1, extVtep1))
+		if err := setNamespaceExternalGateway(f.Namespace.Name, extGw1, extVtep1); err != nil {
+			framework.Failf("failed to annotate the test namespace: %v", err)
+		}
+		framework.ExpectNoError(waitForPodExternalGateway(f, oldPod, extGw1, extVtep1))
+
+		By("Creating a pod after the namespace is annotated")
+		createGenericPod(f, newPod, "", command)
+		framework.ExpectNoError(waitForPodExternalGateway(f, newPod, extGw1, extVtep1))
+
+		By(fmt.Sprintf("Updating the namespace to external gateway %s and vtep %s", extGw2, extVtep2))
+		if err := setNamespaceExternalGateway(f.Namespace.Name, extGw2, extVtep2); err != nil {
+			framework.Failf("failed to update the test namespace annotations: %v", err)
+		}
+		framework.ExpectNoError(waitForPodExternalGateway(f, oldPod, extGw2, extVtep2))
+		framework.ExpectNoError(waitForPodExternalGateway(f, newPod, extGw2, extVtep2))
+	})
+})
+
+// Test that the master programs the src-ip routes of a namespace's routing
+// external gateways on the gateway router of its pods' node
+var _ = Describe("e2e routing external gateway validation", func() {
+	const (
+		svcname string = "routing-externalgw"
+		ovnNs   string = "ovn-kubernetes"
+		extGw   string = "10.249.5.1"
+		extGw2  string = "10.249.5.2"
+		podName string = "e2e-routing-exgw-pod"
+		// gwHost is both the name of the gateway container and the
+		// hostname used in the annotation
+		gwHost string = "routing-exgw-host"
+	)
+	command := []string{"bash", "-c", "sleep 20000"}
+
+	f := framework.NewDefaultFramework(svcname)
+
+	It("Should add the pod's external gateway route to the gateway router of its node", func() {
+		By(fmt.Sprintf("Annotating the namespace with routing external gateway %s", extGw))
+		_, err := framework.RunKubectl("annotate", "namespace", f.Namespace.Name,
+			fmt.Sprintf("%s=%s", routingExGwAnnotation, extGw), "--overwrite")
+		if err != nil {
+			framework.Failf("failed to annotate the test namespace: %v", err)
+		}
+
+		By("Creating a pod in the annotated namespace")
+		createGenericPod(f, podName, "", command)
+		pod, err := f.ClientSet.CoreV1().Pods(f.Namespace.Name).Get(podName, metav1.GetOptions{})
+		framework.ExpectNoError(err, "should get the test pod")
+		if ip := net.ParseIP(pod.Status.PodIP); ip == nil || ip.To4() == nil {
+			framework.Skipf("Routing external gateway validation needs an IPv4 pod address, got %q", pod.Status.PodIP)
+		}
+
+		By(fmt.Sprintf("Checking the route via %s on GR_%s", extGw, pod.Spec.NodeName))
+		framework.ExpectNoError(waitForPodExternalRoutes(f, ovnNs, pod.Spec.NodeName, pod.Status.PodIP, []string{extGw}))
+	})
+
+	It("Should keep the pod's ecmp external gateway routes across an ovnkube-master restart", func() {
+		gateways := []string{extGw, extGw2}
+		By(fmt.Sprintf("Annotating the namespace with routing external gateways %v", gateways))
+		_, err := framework.RunKubectl("annotate", "namespace", f.Namespace.Name,
+			fmt.Sprintf("%s=%s", routingExGwAnnotation, strings.Join(gateways, ",")), "--overwrite")
+		if err != nil {
+			framework.Failf("failed to annotate the test namespace: %v", err)
+		}
+
+		By("Creating a pod in the annotated namespace")
+		createGenericPod(f, podName, "", command)
+		pod, err := f.ClientSet.CoreV1().Pods(f.Namespace.Name).Get(podName, metav1.GetOptions{})
+		framework.ExpectNoError(err, "should get the test pod")
+		if ip := net.ParseIP(pod.Status.PodIP); ip == nil || ip.To4() == nil {
+			framework.Skipf("Routing external gateway validation needs an IPv4 pod address, got %q", pod.Status.PodIP)
+		}
+
+		By(fmt.Sprintf("Checking the ecmp routes via %v on GR_%s", gateways, pod.Spec.NodeName))
+		framework.ExpectNoError(waitForPodExternalRoutes(f, ovnNs, pod.Spec.NodeName, pod.Status.PodIP, gateways))
+
+		By("Restarting ovnkube-master")
+		framework.ExpectNoError(restartOVNKubeMaster(f, ovnNs), "should restart ovnkube-master")
+
+		By(fmt.Sprintf("Checking the ecmp routes via %v on GR_%s again", gateways, pod.Spec.NodeName))
+		framework.ExpectNoError(waitForPodExternalRoutes(f, ovnNs, pod.Spec.NodeName, pod.Status.PodIP, gateways))
+	})
+
+	// ovnkube-master runs in the host network of its kind node, so it
+	// resolves names through docker's embedded DNS server rather than
+	// through the cluster DNS. That server resolves the names of the
+	// containers on the kind network, so a gateway container's name is
+	// used as the gateway hostname.
+	It("Should resolve a gateway hostname and add the pod's route via its IP", func() {
+		err := startGatewayContainer(gwHost, "--network", "kind")
+		if err != nil {
+			framework.Failf("failed to start the gateway container %s: %v", gwHost, err)
+		}
+		defer func() {
+			dumpContainerLogsOnFailure(gwHost)
+			if _, err := runCommand("docker", "rm", "-f", gwHost); err != nil {
+				framework.Logf("failed to delete the gateway container %s: %v", gwHost, err)
+			}
+		}()
+		gwIP, err := runCommand("docker", "inspect", "-f", "{{ .NetworkSettings.Networks.kind.IPAddress }}", gwHost)
+		if err != nil {
+			framework.Failf("failed to inspect the gateway container %s: %v", gwHost, err)
+		}
+		gwIP = strings.TrimSpace(gwIP)
+		if ip := net.ParseIP(gwIP); ip == nil {
+			framework.Skipf("Gateway hostname resolution needs an IPv4 address on the kind network, got %q", gwIP)
+		}
+
+		By(fmt.Sprintf("Annotating the namespace with routing external gateway %s", gwHost))
+		_, err = framework.RunKubectl("annotate", "namespace", f.Namespace.Name,
+			fmt.Sprintf("%s=%s", routingExGwAnnotation, gwHost), "--overwrite")
+		if err != nil {
+			framework.Failf("failed to annotate the test namespace: %v", err)
+		}
+
+		By("Creating a pod in the annotated namespace")
+		createGenericPod(f, podName, "", command)
+		pod, err := f.ClientSet.CoreV1().Pods(f.Namespace.Name).Get(podName, metav1.GetOptions{})
+		framework.ExpectNoError(err, "should get the test pod")
+		if ip := net.ParseIP(pod.Status.PodIP); ip == nil || ip.To4() == nil {
+			framework.Skipf("Routing external gateway validation needs an IPv4 pod address, got %q", pod.Status.PodIP)
+		}
+
+		By(fmt.Sprintf("Checking the route via %s (%s) on GR_%s", gwIP, gwHost, pod.Spec.NodeName))
+		framework.ExpectNoError(waitForPodExternalRoutes(f, ovnNs, pod.Spec.NodeName, pod.Status.PodIP, []string{gwIP}))
+	})
+})
+
+// getNodePodCIDRs returns the parsed pod subnets of the given node, one per
+// IP family on dual-stack clusters
+func getNodePodCIDRs(nodeName string) ([]*net.IPNet, error) {
 	// retrieve the pod cidr for the worker node
 	jsonFlag := "jsonpath='{.metadata.annotations.k8s\\.ovn\\.org/node-subnets}'"
 	kubectlOut, err := framework.RunKubectl("get", "node", nodeName, "-o", jsonFlag)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	// strip the apostrophe from stdout and parse the pod cidr
 	annotation := strings.Replace(kubectlOut, "'", "", -1)
 
+	var subnets []string
 	ssSubnets := make(map[string]string)
+	dsSubnets := make(map[string][]string)
 	if err := json.Unmarshal([]byte(annotation), &ssSubnets); err == nil {
-		return ssSubnets["default"], nil
+		subnets = []string{ssSubnets["default"]}
+	} else if err := json.Unmarshal([]byte(annotation), &dsSubnets); err == nil {
+		subnets = dsSubnets["default"]
+	} else {
+		return nil, fmt.Errorf("could not parse annotation %q", annotation)
 	}
-	dsSubnets := make(map[string][]string)
-	if err := json.Unmarshal([]byte(annotation), &dsSubnets); err == nil {
-		return dsSubnets["default"][0], nil
+
+	var cidrs []*net.IPNet
+	for _, subnet := range subnets {
+		_, cidr, err := net.ParseCIDR(subnet)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse subnet %q of node %s: %v", subnet, nodeName, err)
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	if len(cidrs) == 0 {
+		return nil, fmt.Errorf("node %s has no pod subnet in annotation %q", nodeName, annotation)
+	}
+	return cidrs, nil
+}
+
+// getNodePodCIDR returns the first pod subnet of the given node
+func getNodePodCIDR(nodeName string) (string, error) {
+	cidrs, err := getNodePodCIDRs(nodeName)
+	if err != nil {
+		return "", err
 	}
-	return "", fmt.Errorf("could not parse annotation %q", annotation)
+	return cidrs[0].String(), nil
 }